@@ -0,0 +1,92 @@
+package main
+
+// Per-armor ASCII art for the TUI art panel, keyed by item ID.
+
+const oathplateHelmetArt = `
+        @adJOJJcJJOJ                        
+     aaMMMaaaaaddOJc|                      
+     J@@@@aMaaaaaaddOcc @Mjj|              
+      MOOddaaMaaaddadddaOadJadj            
+      j|   @OadMaaaaddJadcOdjc|            
+            M OdaaaaadO@cO@@cJ-            
+               MaaaaaaO@ac@@JJ{            
+              cOaddaaad@@|@@cO{        >I  
+                 caaMaaM@Md@M@@        |"  
+          I|        a@@@@@@@@{        >c"  
+          {|{         |>-   "MM       jc   
+       |   dJ|I    >--|I  >I -@@{    j@O   
+       ||{{jaO|-I --ddOMJ I>I -@@d     a>  
+         JdaaMdOja-cdMdOaO     "ad@""> ""  
+          MMad{|{-{@MMMaJac|jj{>OcaJ>>>I-  
+         {cO@@@O|{-j@MdaMMc-{{{>|Od|I>I">" 
+          OdMaccc|{{a@dOOda{--{>-ddj">"|M> 
+             @@@ac{{cMMdddM@M{---Jac""|M@@O
+                @@O{{a@@@@@adaa-I{MO -M@J  
+                @@aj||I"{M@@@@@@M|Mdc@@d   
+                 @@OjddcI     -OM@@@c    I 
+                J{@@cMMdc@||>          "-- 
+               -jaM@a@@aJ@Occ{@>{{-I->c@@  
+             -|JacJd@@@@M@@c{d@J-|j{>-@O   
+             {j|-{jjjc@@@@@M|@@@j{|-I@@"   
+                 "{-c@@@@@@@a@@@@|{>@@@    
+                  >M@@@@@@@@@@@@@@|@@      
+                    adaM@@MMadJcjOj>       
+                     OOOJccjj{j>          
+
+`
+
+const oathplateChestplateArt = `
+              jjc{|       |{cjj              
+          -cJOMMMaO{-   -{OaMMMOJc-          
+        {aM@@@@@@@Md{   {dM@@@@@@@Ma{        
+       j@@@@@MMMM@@@@aOa@@@@MMMM@@@@@j       
+      c@@@@Ma{{{aM@@@@@@@@@Ma{{{aM@@@@c      
+      O@@@@d|     {aM@@@Ma{     |d@@@@O      
+      O@@@@J        jM@Mj        J@@@@O      
+      |aMMMd-   -|cJd@@@dJc|-   -dMMMa|      
+        -{OMaOdaaMM@@@@@@@MMaadOaMO{-        
+          a@@@@@@@@@@@@@@@@@@@@@@@a          
+          d@@@MaaaaaMM@@@MMaaaaaM@@d          
+          d@@@O{-  -|jOaOj|-  -{O@@d          
+          a@@@@MadOOdaM@MadOOdaM@@@a          
+          O@@@@@@@@@@@@@@@@@@@@@@@@O          
+          J@@@@MMMMMMM@@@MMMMMMM@@@J          
+          c@@@MJ|-  -jM@Mj-  -|JM@@c          
+          {M@@@@MaaaaM@@@MaaaaM@@@@{          
+           dM@@@@@@@@@@@@@@@@@@@@Md           
+           -JaM@@@@@@@@@@@@@@@@MaJ-           
+              -|jOdaaMMMMMaadOj|-             
+`
+
+const oathplateLegsArt = `
+           {cJOOdddddddddddOOJc{             
+           O@@@@@@@@@@@@@@@@@@@O             
+           O@@MaaaaaaM@MaaaaaaM@O            
+           d@@O{-----J@J-----{O@d            
+           a@@@MaaaaM@@@MaaaaM@@a            
+           a@@@@@@@@@@|@@@@@@@@@a            
+          -M@@@@@@@@@a a@@@@@@@@@M-          
+          j@@@@@@@@@@O O@@@@@@@@@@j          
+          O@@@MMMMM@@c c@@MMMMM@@@O          
+          a@@a{--{a@@| |@@a{--{a@@a          
+          M@@@MaaM@@@- -@@@MaaM@@@M          
+         -@@@@@@@@@@d   d@@@@@@@@@@-         
+         j@@@@@@@@@@J   J@@@@@@@@@@j         
+         O@@@@MMMM@@c   c@@MMMM@@@@O         
+         a@@@J{--{O@|   |@O{--{J@@@a         
+         M@@@@MaaM@@-   -@@MaaM@@@@M         
+        -@@@@@@@@@@d     d@@@@@@@@@@-        
+        |dM@@@@@@@Mj     jM@@@@@@@Md|        
+         -{cJOdaaOc-     -cOaadOJc{-         
+`
+
+type armorArtwork struct {
+	Title string
+	Art   string
+}
+
+var armorArt = map[int]armorArtwork{
+	armorID1: {"Oathplate Helmet", oathplateHelmetArt},
+	armorID2: {"Oathplate Chestplate", oathplateChestplateArt},
+	armorID3: {"Oathplate Legs", oathplateLegsArt},
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// cacheSchemaVersion is the layout saveCache writes. Bump it together with a
+// new entry in cacheMigrations.
+const cacheSchemaVersion = 2
+
+type CacheFile struct {
+	Version int      `json:"version"`
+	State   AppState `json:"state"`
+}
+
+// cacheTTL is how long fetched prices count as fresh (-ttl).
+var cacheTTL = defaultTTL
+
+// errCorruptCache marks a cache file that could not be decoded or migrated.
+// The bad file has already been moved aside when this is returned.
+var errCorruptCache = errors.New("corrupt cache")
+
+/*
+   CACHE
+*/
+
+// loadCache reads and migrates the cache. A missing file returns an error
+// matching fs.ErrNotExist.
+func loadCache() (CacheFile, error) {
+	var b []byte
+	err := withFileLock(cachePath(), false, func() error {
+		var err error
+		b, err = os.ReadFile(cachePath())
+		return err
+	})
+	if err != nil {
+		return CacheFile{}, err
+	}
+
+	c, err := decodeCache(b)
+	if err == nil {
+		return c, nil
+	}
+	if errors.Is(err, errNewerCache) {
+		return CacheFile{}, err
+	}
+
+	backup, berr := backupCorruptCache(b)
+	if berr != nil {
+		return CacheFile{}, fmt.Errorf("%w: %v (backup failed: %v)", errCorruptCache, err, berr)
+	}
+	return CacheFile{}, fmt.Errorf("%w: %v (moved to %s)", errCorruptCache, err, backup)
+}
+
+// loadCachedState is the common "cache or defaults" lookup. A missing cache
+// is not an error; a corrupt one is reported but defaults are still returned.
+func loadCachedState() (AppState, error) {
+	c, err := loadCache()
+	if errors.Is(err, fs.ErrNotExist) {
+		return defaultState(), nil
+	}
+	if err != nil {
+		return defaultState(), err
+	}
+	return c.State, nil
+}
+
+// saveCache writes state as the current schema. A cache written by a newer
+// build is left alone (errNewerCache): this build can't know what it would
+// drop from it.
+func saveCache(state AppState) error {
+	c := CacheFile{Version: cacheSchemaVersion, State: state}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return withFileLock(cachePath(), true, func() error {
+		if cur, err := os.ReadFile(cachePath()); err == nil {
+			if v := cacheVersion(cur); v > cacheSchemaVersion {
+				return fmt.Errorf("%w (schema %d), not overwriting it", errNewerCache, v)
+			}
+		}
+		return writeFileAtomic(cachePath(), b, 0o644)
+	})
+}
+
+// mergeIntoCache saves freshly fetched prices, keeping the unexpired
+// overrides of the cached state, and returns what was saved. Reading and
+// writing happen under one exclusive lock so an override added by another
+// process in between is not lost. A corrupt cache is backed up first.
+func mergeIntoCache(fetched AppState) (AppState, error) {
+	err := withFileLock(cachePath(), true, func() error {
+		cur, err := os.ReadFile(cachePath())
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return err
+		default:
+			c, err := decodeCache(cur)
+			switch {
+			case err == nil:
+				fetched = withOverridesFrom(fetched, c.State)
+			case errors.Is(err, errNewerCache):
+				return fmt.Errorf("%w, not overwriting it", err)
+			default:
+				if err := os.WriteFile(corruptCachePath(), cur, 0o644); err != nil {
+					return fmt.Errorf("back up corrupt cache: %w", err)
+				}
+			}
+		}
+
+		b, err := json.MarshalIndent(CacheFile{Version: cacheSchemaVersion, State: fetched}, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(cachePath(), b, 0o644)
+	})
+	return fetched, err
+}
+
+// backupCorruptCache moves the unreadable file aside so the next save does
+// not overwrite it. If another process replaced the file in the meantime the
+// new one is left alone and the bad bytes are written to the backup instead.
+func backupCorruptCache(bad []byte) (string, error) {
+	backup := corruptCachePath()
+	err := withFileLock(cachePath(), true, func() error {
+		cur, err := os.ReadFile(cachePath())
+		if err == nil && bytes.Equal(cur, bad) {
+			return os.Rename(cachePath(), backup)
+		}
+		return os.WriteFile(backup, bad, 0o644)
+	})
+	return backup, err
+}
+
+func corruptCachePath() string {
+	return fmt.Sprintf("%s.corrupt-%s", cachePath(), time.Now().Format("20060102-150405"))
+}
+
+// stateAge reports how old the fetched prices are and whether they are
+// still within cacheTTL. Never-fetched state is zero age and not fresh.
+func stateAge(s AppState) (time.Duration, bool) {
+	if s.FetchedAt.IsZero() {
+		return 0, false
+	}
+	age := time.Since(s.FetchedAt)
+	return age, age <= cacheTTL
+}
+
+/*
+   SCHEMA MIGRATIONS
+*/
+
+var errNewerCache = errors.New("cache was written by a newer version")
+
+// cacheMigrations[i] upgrades a raw version-i document to version i+1. A
+// nil entry only bumps the version number.
+var cacheMigrations = []func(map[string]json.RawMessage) error{
+	migrateCacheV0,
+	// 1 -> 2 added state.overrides. Version 1 documents read as they are
+	// (manual prices were mixed into the fetched triples and can't be told
+	// apart); the bump is there so a version-1 build, which would drop the
+	// overrides on its next save, sees the file as newer and leaves it alone.
+	nil,
+}
+
+// cacheVersion peeks at the schema version of an encoded cache. Unversioned
+// or unreadable documents count as 0.
+func cacheVersion(b []byte) int {
+	var doc struct {
+		Version int `json:"version"`
+	}
+	_ = json.Unmarshal(b, &doc)
+	return doc.Version
+}
+
+func decodeCache(b []byte) (CacheFile, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return CacheFile{}, err
+	}
+	if doc == nil {
+		return CacheFile{}, errors.New("cache is not a JSON object")
+	}
+
+	v := 0
+	if raw, ok := doc["version"]; ok {
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return CacheFile{}, fmt.Errorf("version: %w", err)
+		}
+	}
+	if v > cacheSchemaVersion {
+		return CacheFile{}, fmt.Errorf("%w (schema %d, this build reads up to %d)", errNewerCache, v, cacheSchemaVersion)
+	}
+	for ; v < cacheSchemaVersion; v++ {
+		if migrate := cacheMigrations[v]; migrate != nil {
+			if err := migrate(doc); err != nil {
+				return CacheFile{}, fmt.Errorf("migrate schema %d -> %d: %w", v, v+1, err)
+			}
+		}
+		doc["version"] = json.RawMessage(fmt.Sprint(v + 1))
+	}
+
+	norm, err := json.Marshal(doc)
+	if err != nil {
+		return CacheFile{}, err
+	}
+	var c CacheFile
+	if err := json.Unmarshal(norm, &c); err != nil {
+		return CacheFile{}, err
+	}
+	return c, nil
+}
+
+// migrateCacheV0 upgrades the unversioned layout: a bare state whose armor
+// list may be short or missing names/IDs, with avg sometimes left at zero
+// when only high/low were set.
+func migrateCacheV0(doc map[string]json.RawMessage) error {
+	raw, ok := doc["state"]
+	if !ok {
+		return errors.New("missing state")
+	}
+	var st AppState
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return err
+	}
+
+	def := defaultState()
+	for i := range def.Armors {
+		if i >= len(st.Armors) {
+			st.Armors = append(st.Armors, def.Armors[i])
+			continue
+		}
+		if st.Armors[i].ItemID == 0 {
+			st.Armors[i].ItemID = def.Armors[i].ItemID
+		}
+		if st.Armors[i].Name == "" {
+			st.Armors[i].Name = def.Armors[i].Name
+		}
+	}
+
+	fillAvg := func(t *PriceTriple) {
+		if t.Avg == 0 && t.High != 0 && t.Low != 0 {
+			t.Avg = (t.High + t.Low) / 2
+		}
+	}
+	fillAvg(&st.Shale)
+	fillAvg(&st.Shard)
+	for i := range st.Armors {
+		fillAvg(&st.Armors[i].Price)
+	}
+	if st.Mode == "" {
+		st.Mode = "manual"
+	}
+
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	doc["state"] = b
+	return nil
+}
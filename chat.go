@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	colourProfit = 0x2ecc71
+	colourLoss   = 0xe74c3c
+	colourNoData = 0x95a5a6
+)
+
+/*
+   CHAT (Discord / Slack webhook payloads)
+*/
+
+type discordPayload struct {
+	Embeds []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+type slackPayload struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Title  string       `json:"title"`
+	Text   string       `json:"text,omitempty"`
+	Fields []slackField `json:"fields,omitempty"`
+	Footer string       `json:"footer,omitempty"`
+	Ts     int64        `json:"ts,omitempty"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// RenderChatJSON renders the report as a webhook body for "discord" or
+// "slack".
+func RenderChatJSON(r Report, format string) ([]byte, error) {
+	switch format {
+	case "discord":
+		return json.MarshalIndent(discordMessage(r), "", "  ")
+	case "slack":
+		return json.MarshalIndent(slackMessage(r), "", "  ")
+	default:
+		return nil, fmt.Errorf("unknown chat format %q (use discord|slack)", format)
+	}
+}
+
+func discordMessage(r Report) discordPayload {
+	e := discordEmbed{
+		Title:       "Oathplate margins",
+		Description: chatSummary(r),
+		Color:       chatColour(r),
+		Footer:      &discordFooter{Text: fmt.Sprintf("OathPlate Calculator %s · %s prices", r.Version, r.Mode)},
+	}
+	if !r.FetchedAt.IsZero() {
+		e.Timestamp = r.FetchedAt.UTC().Format(time.RFC3339)
+	}
+	for _, a := range r.Armors {
+		e.Fields = append(e.Fields, discordField{Name: a.Name, Value: chatArmorLines(a), Inline: true})
+	}
+	return discordPayload{Embeds: []discordEmbed{e}}
+}
+
+func slackMessage(r Report) slackPayload {
+	a := slackAttachment{
+		Color:  fmt.Sprintf("#%06x", chatColour(r)),
+		Title:  "Oathplate margins",
+		Text:   chatSummary(r),
+		Footer: fmt.Sprintf("OathPlate Calculator %s · %s prices", r.Version, r.Mode),
+	}
+	if !r.FetchedAt.IsZero() {
+		a.Ts = r.FetchedAt.Unix()
+	}
+	for _, ar := range r.Armors {
+		a.Fields = append(a.Fields, slackField{Title: ar.Name, Value: chatArmorLines(ar), Short: true})
+	}
+	return slackPayload{Text: "Oathplate margins", Attachments: []slackAttachment{a}}
+}
+
+func chatSummary(r Report) string {
+	if len(r.Armors) == 0 {
+		return fmt.Sprintf("Ingredients (avg): %s gp\nNo armors to compare", comma(r.IngredientCost.Avg))
+	}
+	return fmt.Sprintf("Ingredients (avg): %s gp\nBest by avg profit: %s (%s gp)",
+		comma(r.IngredientCost.Avg),
+		r.BestByAvgProfit.Name,
+		comma(profitForLabel(r.BestByAvgProfit, "avg")),
+	)
+}
+
+func chatArmorLines(a ArmorReport) string {
+	var b strings.Builder
+	for i, c := range a.Cases {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s: %s gp", c.SaleLabel, comma(c.Profit))
+	}
+	return b.String()
+}
+
+// chatColour is green when the best avg case makes money, red when it
+// doesn't, grey when there are no prices at all.
+func chatColour(r Report) int {
+	if len(r.Armors) == 0 || r.IngredientCost.Avg == 0 {
+		return colourNoData
+	}
+	if profitForLabel(r.BestByAvgProfit, "avg") > 0 {
+		return colourProfit
+	}
+	return colourLoss
+}
+
+/*
+   POST (send to webhook)
+*/
+
+func runPost(args []string) error {
+	fs := flag.NewFlagSet("post", flag.ContinueOnError)
+	webhook := fs.String("webhook", os.Getenv("OATHPLATE_WEBHOOK_URL"), "webhook URL (default $OATHPLATE_WEBHOOK_URL)")
+	format := fs.String("format", "discord", "payload format: discord|slack")
+	dryRun := fs.Bool("dry-run", false, "print the payload instead of sending it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	state, err := loadCachedState()
+	if err != nil {
+		return err
+	}
+	body, err := RenderChatJSON(ComputeReport(state), *format)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		fmt.Println(string(body))
+		return nil
+	}
+	if *webhook == "" {
+		return errors.New("no webhook URL (use -webhook or OATHPLATE_WEBHOOK_URL)")
+	}
+	return postWebhook(*webhook, body)
+}
+
+func postWebhook(url string, body []byte) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	fmt.Println("Posted.")
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"cmp"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+/*
+   CLIPBOARD (OSC 52)
+*/
+
+// copyTargets are what the TUI can copy, for the copy command.
+var copyTargets = []string{"report", "armor", "breakeven", "sale"}
+
+// osc52 is the escape sequence asking the terminal to put text on the system
+// clipboard. It travels with the normal output, so it also works over SSH.
+// Inside tmux the sequence is sent a second time wrapped for passthrough, for
+// setups where tmux does not take OSC 52 itself (set-clipboard off).
+func osc52(text string) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if os.Getenv("TMUX") != "" {
+		seq += "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	return seq
+}
+
+// copyToClipboard writes text to the clipboard through the terminal. Call it
+// from the UI goroutine so it doesn't interleave with a screen update.
+func copyToClipboard(screen tcell.Screen, text string) error {
+	if screen == nil {
+		return errors.New("no terminal yet")
+	}
+	tty, ok := screen.Tty()
+	if !ok {
+		screen.SetClipboard([]byte(text))
+		return nil
+	}
+	_, err := io.WriteString(tty, osc52(text))
+	return err
+}
+
+// armorClipLine is one armor on a single line, for pasting into chat.
+func armorClipLine(a ArmorReport, r Report) string {
+	basis := cmp.Or(r.SaleBasis, "avg")
+	return fmt.Sprintf("%s: sale %s / %s / %s gp (high/low/avg), profit @%s %s gp, roi %.1f%%",
+		a.Name, comma(a.Sale.High), comma(a.Sale.Low), comma(a.Sale.Avg),
+		basis, signedComma(profitForLabel(a, basis)), roiForLabel(a, basis)*100)
+}
+
+// breakEvenClip is the lowest sale price that covers the ingredients at the
+// sale basis tier, as plain digits for the GE price box.
+func breakEvenClip(r Report, p Profile) string {
+	cost := r.IngredientCost.Avg
+	switch r.SaleBasis {
+	case "low":
+		cost = r.IngredientCost.Low
+	case "high":
+		cost = r.IngredientCost.High
+	}
+	return strconv.FormatInt(p.breakEven(cost), 10)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+/*
+   COMMAND PALETTE (':' line in the TUI)
+*/
+
+// paletteCommands are the verbs the TUI command line accepts, with usage
+// for the help text and error messages.
+var paletteCommands = []struct {
+	Name, Usage string
+}{
+	{"set", "set <field> <value>[@ttl]   e.g. set shale.low 29k, set legs 1.2m@2h"},
+	{"clear", "clear <field>|all"},
+	{"fetch", "fetch [force]"},
+	{"load", "load"},
+	{"save", "save"},
+	{"scenario", "scenario load|save|compare [name] [adjustments...]"},
+	{"export", "export csv|html [file]"},
+	{"copy", "copy " + strings.Join(copyTargets, "|")},
+	{"sort", "sort " + strings.Join(sortKeys, "|")},
+	{"profile", "profile [name]"},
+	{"undo", "undo"},
+	{"redo", "redo"},
+	{"history", "history"},
+	{"auto", "auto"},
+	{"avg", "avg"},
+	{"theme", "theme [" + strings.Join(themeNames(), "|") + "]"},
+	{"errors", "errors"},
+	{"keys", "keys"},
+	{"quit", "quit"},
+}
+
+func paletteUsage(verb string) string {
+	for _, c := range paletteCommands {
+		if c.Name == verb {
+			return "usage: " + c.Usage
+		}
+	}
+	return ""
+}
+
+// fieldNames lists everything ApplyManualSet accepts as a field: each
+// target and alias, bare and with a component.
+func fieldNames() []string {
+	targets := []string{"shale", "shard", "armor1", "armor2", "armor3"}
+	targets = append(targets, slices.Sorted(maps.Keys(targetAliases))...)
+	var out []string
+	for _, t := range targets {
+		out = append(out, t)
+		for _, c := range []string{"high", "low", "avg"} {
+			out = append(out, t+"."+c)
+		}
+	}
+	return out
+}
+
+// paletteCandidates returns the possible words after the given complete
+// words of a command line.
+func paletteCandidates(words []string) []string {
+	if len(words) == 0 {
+		out := make([]string, len(paletteCommands))
+		for i, c := range paletteCommands {
+			out[i] = c.Name
+		}
+		return out
+	}
+	n := len(words)
+	switch words[0] {
+	case "set":
+		if n == 1 {
+			return fieldNames()
+		}
+	case "clear":
+		if n == 1 {
+			return append([]string{"all"}, fieldNames()...)
+		}
+	case "fetch":
+		if n == 1 {
+			return []string{"force"}
+		}
+	case "scenario":
+		if n == 1 {
+			return []string{"load", "save", "compare"}
+		}
+		if n == 2 && words[1] == "load" {
+			names, _ := listScenarios()
+			return names
+		}
+	case "export":
+		if n == 1 {
+			return exportFormats
+		}
+	case "sort":
+		if n == 1 {
+			return sortKeys
+		}
+	case "copy":
+		if n == 1 {
+			return copyTargets
+		}
+	case "profile":
+		if n == 1 {
+			names, _ := listProfiles()
+			return names
+		}
+	case "theme":
+		if n == 1 {
+			return themeNames()
+		}
+	}
+	return nil
+}
+
+// completeCommand completes the last word of line. One match is filled in
+// with a trailing space; several are extended to their common prefix and
+// returned so the caller can show them.
+func completeCommand(line string) (string, []string) {
+	words := strings.Fields(line)
+	cur := ""
+	if len(words) > 0 && !strings.HasSuffix(line, " ") {
+		cur, words = words[len(words)-1], words[:len(words)-1]
+	}
+
+	var matches []string
+	for _, c := range paletteCandidates(words) {
+		if strings.HasPrefix(c, cur) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return line, nil
+	}
+
+	prefix := strings.Join(words, " ")
+	if prefix != "" {
+		prefix += " "
+	}
+	if len(matches) == 1 {
+		return prefix + matches[0] + " ", nil
+	}
+	common := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	return prefix + common, matches
+}
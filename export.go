@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	_ "embed"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed web/report.html.tmpl
+var reportHTMLTemplate string
+
+/*
+   EXPORT
+*/
+
+func runExport(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("export needs a format (%s)", strings.Join(exportFormats, "|"))
+	}
+	format, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("export "+format, flag.ContinueOnError)
+	out := fs.String("o", "", "output file (default oathplate_report.<format>)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = "oathplate_report." + format
+	}
+
+	state, err := loadCachedState()
+	if err != nil {
+		return err
+	}
+	body, err := exportReport(format, ComputeReport(state))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, body, 0o644); err != nil {
+		return err
+	}
+	fmt.Println("Wrote", *out)
+	return nil
+}
+
+var exportFormats = []string{"html", "csv"}
+
+// exportReport renders r in one of exportFormats.
+func exportReport(format string, r Report) ([]byte, error) {
+	switch format {
+	case "html":
+		hist, err := loadHistory()
+		if err != nil {
+			return nil, err
+		}
+		return RenderReportHTML(r, hist)
+	case "csv":
+		return RenderReportCSV(r)
+	default:
+		return nil, fmt.Errorf("unknown export format %q (use %s)", format, strings.Join(exportFormats, "|"))
+	}
+}
+
+/*
+   CSV REPORT
+*/
+
+// RenderReportCSV writes one row per armor and sale case, in the report's
+// sort order.
+func RenderReportCSV(r Report) ([]byte, error) {
+	armors := append([]ArmorReport(nil), r.Armors...)
+	sortArmors(armors, cmp.Or(r.SortBy, sortKeys[0]), cmp.Or(r.SaleBasis, "avg"))
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Write([]string{"armor", "item_id", "sale_case", "sale_price", "ingredient_cost", "tax", "net", "profit", "roi_pct", "fetched_at"})
+	fetched := ""
+	if !r.FetchedAt.IsZero() {
+		fetched = r.FetchedAt.UTC().Format(time.RFC3339)
+	}
+	for _, a := range armors {
+		for _, c := range a.Cases {
+			cw.Write([]string{
+				a.Name,
+				strconv.Itoa(a.ItemID),
+				c.SaleLabel,
+				strconv.FormatInt(c.SalePrice, 10),
+				strconv.FormatInt(c.NetAfterTax-c.Profit, 10),
+				strconv.FormatInt(c.TaxPaid, 10),
+				strconv.FormatInt(c.NetAfterTax, 10),
+				strconv.FormatInt(c.Profit, 10),
+				strconv.FormatFloat(c.roi()*100, 'f', 2, 64),
+				fetched,
+			})
+		}
+	}
+	cw.Flush()
+	return buf.Bytes(), cw.Error()
+}
+
+/*
+   HTML REPORT
+*/
+
+type htmlReportData struct {
+	Report      Report
+	Generated   time.Time
+	Best        int
+	PriceCharts []template.HTML
+	ProfitChart template.HTML
+	HistoryLen  int
+}
+
+// RenderReportHTML produces a single self-contained page: inline CSS and
+// SVG, no scripts or external assets.
+func RenderReportHTML(r Report, history []AppState) ([]byte, error) {
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"gp":      comma,
+		"upper":   strings.ToUpper,
+		"when":    func(t time.Time) string { return t.Local().Format("2006-01-02 15:04:05") },
+		"age":     roundDuration,
+		"profitc": func(n int64) string { return boolWord(n < 0, "neg", "pos") },
+	}).Parse(reportHTMLTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	data := htmlReportData{
+		Report:     r,
+		Generated:  time.Now(),
+		Best:       r.BestByAvgProfit.ItemID,
+		HistoryLen: len(history),
+	}
+	if len(history) >= 2 {
+		data.PriceCharts, data.ProfitChart = historyCharts(history)
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+type chartSeries struct {
+	Name   string
+	Colour string
+	Points []chartPoint
+}
+
+type chartPoint struct {
+	At    time.Time
+	Value float64
+}
+
+var chartColours = []string{"#e67e22", "#3498db", "#2ecc71", "#e74c3c", "#9b59b6"}
+
+// historyCharts builds the avg price charts (ingredients and armor are on
+// very different scales, so each gets its own) and one avg profit chart.
+func historyCharts(history []AppState) ([]template.HTML, template.HTML) {
+	shale := chartSeries{Name: "Infernal Shale", Colour: chartColours[0]}
+	shard := chartSeries{Name: "Oathplate Shards", Colour: chartColours[1]}
+	armorPrice := map[int]*chartSeries{}
+	armorProfit := map[int]*chartSeries{}
+	var order []int
+
+	// stale items were not fetched that time, so they get no point; a
+	// profit needs all its prices fresh
+	for _, s := range history {
+		stale := func(target string) bool { return slices.Contains(s.Stale, target) }
+		if !stale("shale") {
+			shale.Points = append(shale.Points, chartPoint{s.FetchedAt, float64(s.Shale.Avg)})
+		}
+		if !stale("shard") {
+			shard.Points = append(shard.Points, chartPoint{s.FetchedAt, float64(s.Shard.Avg)})
+		}
+
+		staleArmor := map[int]bool{}
+		for i, a := range s.Armors {
+			staleArmor[a.ItemID] = stale(fmt.Sprintf("armor%d", i+1))
+		}
+		rep := ComputeReport(s)
+		for _, a := range rep.Armors {
+			if _, ok := armorPrice[a.ItemID]; !ok {
+				c := chartColours[(len(order)+2)%len(chartColours)]
+				armorPrice[a.ItemID] = &chartSeries{Name: a.Name, Colour: c}
+				armorProfit[a.ItemID] = &chartSeries{Name: a.Name, Colour: c}
+				order = append(order, a.ItemID)
+			}
+			if staleArmor[a.ItemID] {
+				continue
+			}
+			armorPrice[a.ItemID].Points = append(armorPrice[a.ItemID].Points, chartPoint{s.FetchedAt, float64(a.Sale.Avg)})
+			if !stale("shale") && !stale("shard") {
+				armorProfit[a.ItemID].Points = append(armorProfit[a.ItemID].Points, chartPoint{s.FetchedAt, float64(profitForLabel(a, "avg"))})
+			}
+		}
+	}
+
+	var prices, profits []chartSeries
+	for _, id := range order {
+		prices = append(prices, *armorPrice[id])
+		profits = append(profits, *armorProfit[id])
+	}
+
+	charts := []template.HTML{
+		svgLineChart("Infernal Shale avg (gp)", []chartSeries{shale}),
+		svgLineChart("Oathplate Shards avg (gp)", []chartSeries{shard}),
+		svgLineChart("Armor avg sale (gp)", prices),
+	}
+	return charts, svgLineChart("Profit @ avg (gp)", profits)
+}
+
+func svgLineChart(title string, series []chartSeries) template.HTML {
+	const (
+		w, h   = 640, 220
+		padL   = 90
+		padR   = 16
+		padT   = 28
+		padB   = 40
+		plotW  = w - padL - padR
+		plotH  = h - padT - padB
+		nTicks = 4
+	)
+
+	var t0, t1 time.Time
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, p := range s.Points {
+			if t0.IsZero() || p.At.Before(t0) {
+				t0 = p.At
+			}
+			if p.At.After(t1) {
+				t1 = p.At
+			}
+			lo, hi = math.Min(lo, p.Value), math.Max(hi, p.Value)
+		}
+	}
+	if math.IsInf(lo, 0) {
+		return ""
+	}
+	if hi == lo {
+		hi, lo = hi+1, lo-1
+	}
+	span := t1.Sub(t0)
+	if span <= 0 {
+		span = time.Second
+	}
+
+	x := func(t time.Time) float64 { return padL + float64(t.Sub(t0))/float64(span)*plotW }
+	y := func(v float64) float64 { return padT + (hi-v)/(hi-lo)*plotH }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" class="chart">`, w, h, w, h)
+	fmt.Fprintf(&b, `<text x="%d" y="18" class="title">%s</text>`, padL, template.HTMLEscapeString(title))
+
+	for i := 0; i <= nTicks; i++ {
+		v := lo + (hi-lo)*float64(i)/nTicks
+		fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="grid"/>`, padL, w-padR, y(v), y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" class="axis" text-anchor="end">%s</text>`, padL-6, y(v)+4, comma(int64(math.Round(v))))
+	}
+	if lo < 0 && hi > 0 {
+		fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="zero"/>`, padL, w-padR, y(0), y(0))
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d" class="axis">%s</text>`, padL, h-padB+16, t0.Local().Format("01-02 15:04"))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" class="axis" text-anchor="end">%s</text>`, w-padR, h-padB+16, t1.Local().Format("01-02 15:04"))
+
+	for i, s := range series {
+		var pts []string
+		for _, p := range s.Points {
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", x(p.At), y(p.Value)))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, s.Colour, strings.Join(pts, " "))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`, padL+i*150, h-14, s.Colour)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" class="axis">%s</text>`, padL+i*150+14, h-5, template.HTMLEscapeString(s.Name))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
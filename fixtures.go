@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const fixturesDirName = "fixtures"
+
+// fixtureMode says whether wiki responses are recorded to or replayed from
+// fixturesDir.
+type fixtureMode int
+
+const (
+	fixturesOff    fixtureMode = iota
+	fixturesRecord             // -record: fetch as usual and save each response
+	fixturesReplay             // -offline: never touch the network
+)
+
+var (
+	fixtures    = fixturesOff
+	fixturesDir = ""
+)
+
+/*
+   FIXTURES (record / replay of wiki responses)
+*/
+
+// initFixtures sets the fixture mode from the flags. dir defaults to
+// fixtures/ under the cache directory.
+func initFixtures(offline, record bool, dir string) error {
+	if offline && record {
+		return errors.New("-offline and -record don't mix")
+	}
+	if dir == "" {
+		dir = filepath.Join(cacheBase, fixturesDirName)
+	}
+	fixturesDir = dir
+	switch {
+	case offline:
+		fixtures = fixturesReplay
+	case record:
+		fixtures = fixturesRecord
+		return os.MkdirAll(dir, 0o755)
+	}
+	return nil
+}
+
+var unsafeFixtureChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// fixturePath names the fixture after the URL without its scheme, e.g.
+// prices.runescape.wiki_api_v1_osrs_latest_id_30848.json, so a fixtures
+// directory is easy to read and edit.
+func fixturePath(url string) string {
+	_, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		rest = url
+	}
+	return filepath.Join(fixturesDir, unsafeFixtureChars.ReplaceAllString(rest, "_")+".json")
+}
+
+func readFixture(url string) ([]byte, error) {
+	b, err := os.ReadFile(fixturePath(url))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("offline: no fixture for %s (record one with -record)", url)
+	}
+	return b, err
+}
+
+func writeFixture(url string, body []byte) error {
+	return writeFileAtomic(fixturePath(url), body, 0o644)
+}
+
+// seedOfflineCache replays the fixtures into an empty offline cache, so
+// commands that only read the cache (export, post, /metrics) have prices
+// on the first offline run too.
+func seedOfflineCache() error {
+	if _, err := os.Stat(cachePath()); !errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	st, err := FetchStateFromAPIContext(context.Background(), defaultState(), nil)
+	if err != nil {
+		return err
+	}
+	if err := appendHistory(st); err != nil {
+		return err
+	}
+	_, err = mergeIntoCache(st)
+	return err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+const historyFile = "prices_history.jsonl"
+
+/*
+   HISTORY (append-only, one fetched state per line)
+*/
+
+func appendHistory(state AppState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return withFileLock(historyPath(), true, func() error {
+		f, err := os.OpenFile(historyPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(append(b, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+// loadHistory returns recorded states oldest first. A missing file is an
+// empty history, not an error.
+func loadHistory() ([]AppState, error) {
+	var out []AppState
+	err := withFileLock(historyPath(), false, func() error {
+		var err error
+		out, err = readHistory()
+		return err
+	})
+	return out, err
+}
+
+func readHistory() ([]AppState, error) {
+	f, err := os.Open(historyPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []AppState
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var s AppState
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			return out, fmt.Errorf("%s line %d: %w", historyFile, line, err)
+		}
+		out = append(out, s)
+	}
+	return out, sc.Err()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const httpCacheDirName = "http"
+
+/*
+   HTTP RESPONSE CACHE (on disk, shared by all processes)
+*/
+
+// httpCacheEntry is one stored wiki response with what is needed to
+// revalidate it.
+type httpCacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FreshUntil   time.Time `json:"fresh_until,omitzero"` // zero = revalidate every time
+	StoredAt     time.Time `json:"stored_at"`
+	Body         []byte    `json:"body"`
+}
+
+func (e httpCacheEntry) fresh(now time.Time) bool { return now.Before(e.FreshUntil) }
+
+func httpCacheDir() string { return filepath.Join(cacheBase, httpCacheDirName) }
+
+func httpCachePath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(httpCacheDir(), hex.EncodeToString(sum[:])+".json")
+}
+
+// loadHTTPCache returns the stored response for url, if any. A damaged
+// entry counts as missing; the next response overwrites it.
+func loadHTTPCache(url string) (httpCacheEntry, bool) {
+	b, err := os.ReadFile(httpCachePath(url))
+	if err != nil {
+		return httpCacheEntry{}, false
+	}
+	var e httpCacheEntry
+	if err := json.Unmarshal(b, &e); err != nil || e.URL != url {
+		return httpCacheEntry{}, false
+	}
+	return e, true
+}
+
+func saveHTTPCache(e httpCacheEntry) error {
+	if err := os.MkdirAll(httpCacheDir(), 0o755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(httpCachePath(e.URL), b, 0o644)
+}
+
+func dropHTTPCache(url string) { _ = os.Remove(httpCachePath(url)) }
+
+// cacheControl reads what Cache-Control (and Age) allow: whether the
+// response may be stored, and until when it can be reused without asking.
+func cacheControl(h http.Header, now time.Time) (store bool, freshUntil time.Time) {
+	store = true
+	maxAge := -1
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, val, _ := strings.Cut(strings.TrimSpace(strings.ToLower(d)), "=")
+		switch name {
+		case "no-store":
+			store = false
+		case "no-cache":
+			maxAge = 0
+		case "max-age":
+			if n, err := strconv.Atoi(strings.Trim(val, `"`)); err == nil && maxAge != 0 {
+				maxAge = n
+			}
+		}
+	}
+	if maxAge <= 0 {
+		return store, time.Time{}
+	}
+	age, _ := strconv.Atoi(h.Get("Age"))
+	return store, now.Add(time.Duration(maxAge-max(age, 0)) * time.Second)
+}
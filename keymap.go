@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const keymapFile = "keymap.json"
+
+// keyAction is a TUI command that keys can be bound to. Order here is the
+// order of the help overlay.
+type keyAction struct {
+	Name     string
+	Desc     string
+	Defaults []string
+}
+
+var keyActions = []keyAction{
+	{"fetch", "Fetch prices (skipped while the cache is fresh)", []string{"F5", "f"}},
+	{"force-fetch", "Fetch prices now", []string{"Ctrl+R", "F"}},
+	{"load", "Load prices from the cache", []string{"Ctrl+O", "l", "L"}},
+	{"save", "Save prices to the cache", []string{"Ctrl+S", "s", "S"}},
+	{"auto-refresh", "Toggle auto-refresh", []string{"F6", "a", "A"}},
+	{"clear-overrides", "Clear all manual overrides", []string{"F8", "c", "C"}},
+	{"auto-avg", "Toggle auto avg from high/low", []string{"F11", "g", "G"}},
+	{"scenarios", "Compare scenarios", []string{"F3", "v", "V"}},
+	{"profiles", "Switch profile", []string{"F4", "p", "P"}},
+	{"report", "Focus the report table (Enter: details)", []string{"F2", "r", "R"}},
+	{"art", "Show or hide the art panel", []string{"F9", "t", "T"}},
+	{"theme", "Switch to the next colour theme", []string{"F12", "m", "M"}},
+	{"undo", "Undo the last manual edit", []string{"Ctrl+Z", "u"}},
+	{"redo", "Redo", []string{"Ctrl+Y", "U"}},
+	{"history", "Show or hide the edit history", []string{"F7", "h", "H"}},
+	{"copy-report", "Copy the report to the clipboard", []string{"Alt+c", "y"}},
+	{"copy-armor", "Copy the selected armor's line", []string{"Alt+a", "Y"}},
+	{"copy-breakeven", "Copy the break-even sale price", []string{"Alt+b", "b", "B"}},
+	{"command", "Open the command line", []string{"Ctrl+P", ":"}},
+	{"errors", "Show the fetch error log", []string{"Alt+e", "e", "E"}},
+	{"cancel-fetch", "Cancel a running fetch", []string{"Esc"}},
+	{"help", "Show key bindings", []string{"F1", "?"}},
+	{"quit", "Quit", []string{"Ctrl+Q", "F10", "q", "Q"}},
+}
+
+// keySpec is one parsed binding such as "Ctrl+S", "F5" or "q".
+type keySpec struct {
+	key  tcell.Key
+	ch   rune
+	mod  tcell.ModMask
+	name string // as written, for display
+}
+
+// printable reports whether the binding is a plain character. Those only
+// fire while no text input has focus, so typing never triggers them.
+func (k keySpec) printable() bool {
+	return k.key == tcell.KeyRune && k.mod&(tcell.ModAlt|tcell.ModCtrl) == 0
+}
+
+func (k keySpec) matches(ev *tcell.EventKey) bool {
+	if ev.Key() != k.key {
+		return false
+	}
+	if k.key == tcell.KeyRune {
+		// shift is already folded into the rune
+		return ev.Rune() == k.ch && ev.Modifiers()&tcell.ModAlt == k.mod&tcell.ModAlt
+	}
+	return ev.Modifiers() == k.mod
+}
+
+// parseKeySpec reads "Ctrl+S", "Alt+x", "Shift+F5", "F10", "Esc", "?" and
+// the like. Named keys use tcell's names (Enter, Tab, Up, PgDn, ...).
+func parseKeySpec(s string) (keySpec, error) {
+	rest := s
+	var mod tcell.ModMask
+	for {
+		pfx, tail, ok := strings.Cut(rest, "+")
+		if !ok || tail == "" {
+			break
+		}
+		switch strings.ToLower(pfx) {
+		case "ctrl":
+			mod |= tcell.ModCtrl
+		case "alt":
+			mod |= tcell.ModAlt
+		case "shift":
+			mod |= tcell.ModShift
+		default:
+			return keySpec{}, fmt.Errorf("key %q: unknown modifier %q", s, pfx)
+		}
+		rest = tail
+	}
+
+	key, ch := tcell.KeyRune, rune(0)
+	switch r := []rune(rest); {
+	case len(r) == 1:
+		ch = r[0]
+	case strings.EqualFold(rest, "space"):
+		ch = ' '
+	case len(rest) > 1 && (rest[0] == 'F' || rest[0] == 'f'):
+		n, err := strconv.Atoi(rest[1:])
+		if err != nil || n < 1 || n > 64 {
+			return keySpec{}, fmt.Errorf("key %q: bad function key", s)
+		}
+		key = tcell.KeyF1 + tcell.Key(n-1)
+	default:
+		found := false
+		for k, name := range tcell.KeyNames {
+			if strings.EqualFold(name, rest) && !strings.HasPrefix(name, "Ctrl-") {
+				key, found = k, true
+				break
+			}
+		}
+		if !found {
+			return keySpec{}, fmt.Errorf("key %q: unknown key %q", s, rest)
+		}
+	}
+
+	// let tcell normalise Ctrl+letter into its KeyCtrl* code
+	ev := tcell.NewEventKey(key, ch, mod)
+	return keySpec{key: ev.Key(), ch: ev.Rune(), mod: ev.Modifiers(), name: s}, nil
+}
+
+// Keymap maps keys to actions.
+type Keymap struct {
+	bindings map[string][]keySpec // action -> keys
+}
+
+// defaultKeymap returns the built-in bindings.
+func defaultKeymap() Keymap {
+	km := Keymap{bindings: map[string][]keySpec{}}
+	for _, a := range keyActions {
+		for _, s := range a.Defaults {
+			k, err := parseKeySpec(s)
+			if err != nil {
+				panic(err) // built-in table is wrong
+			}
+			km.bindings[a.Name] = append(km.bindings[a.Name], k)
+		}
+	}
+	return km
+}
+
+func keymapPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, keymapFile), nil
+}
+
+// loadKeymap applies the user's keymap file on top of the defaults. The file
+// maps action names to key lists; a listed action replaces its defaults and
+// an empty list unbinds it:
+//
+//	{"fetch": ["F5", "Ctrl+G"], "quit": ["Ctrl+Q"]}
+//
+// On error the defaults are returned along with it.
+func loadKeymap() (Keymap, error) {
+	km := defaultKeymap()
+	path, err := keymapPath()
+	if err != nil {
+		return km, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return km, nil
+	}
+	if err != nil {
+		return km, err
+	}
+
+	var raw map[string][]string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return km, fmt.Errorf("keymap %s: %w", path, err)
+	}
+	user := defaultKeymap()
+	for action, keys := range raw {
+		if !knownAction(action) {
+			return km, fmt.Errorf("keymap %s: unknown action %q", path, action)
+		}
+		user.bindings[action] = nil
+		for _, s := range keys {
+			k, err := parseKeySpec(s)
+			if err != nil {
+				return km, fmt.Errorf("keymap %s: %w", path, err)
+			}
+			user.bindings[action] = append(user.bindings[action], k)
+		}
+	}
+	if err := user.checkConflicts(); err != nil {
+		return km, fmt.Errorf("keymap %s: %w", path, err)
+	}
+	return user, nil
+}
+
+func knownAction(name string) bool {
+	for _, a := range keyActions {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (km Keymap) checkConflicts() error {
+	seen := map[keySpec]string{}
+	for _, a := range keyActions {
+		for _, k := range km.bindings[a.Name] {
+			id := keySpec{key: k.key, ch: k.ch, mod: k.mod}
+			if other, ok := seen[id]; ok && other != a.Name {
+				return fmt.Errorf("%s is bound to both %s and %s", k.name, other, a.Name)
+			}
+			seen[id] = a.Name
+		}
+	}
+	return nil
+}
+
+// Lookup returns the action bound to ev. typing is true while a text input
+// has focus; plain character bindings are skipped then.
+func (km Keymap) Lookup(ev *tcell.EventKey, typing bool) (string, bool) {
+	for _, a := range keyActions {
+		for _, k := range km.bindings[a.Name] {
+			if typing && k.printable() {
+				continue
+			}
+			if k.matches(ev) {
+				return a.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Keys lists the bindings of an action as written, e.g. "F5/f".
+func (km Keymap) Keys(action string) string {
+	var names []string
+	for _, k := range km.bindings[action] {
+		names = append(names, k.name)
+	}
+	return strings.Join(names, "/")
+}
+
+// Hint is the first key of an action, for short labels like "Fetch (F5)".
+func (km Keymap) Hint(action string) string {
+	if ks := km.bindings[action]; len(ks) > 0 {
+		return ks[0].name
+	}
+	return "unbound"
+}
+
+// Describe renders every action with its keys for the help overlay.
+func (km Keymap) Describe() string {
+	var b strings.Builder
+	for _, a := range keyActions {
+		keys := km.Keys(a.Name)
+		if keys == "" {
+			keys = "(unbound)"
+		}
+		fmt.Fprintf(&b, "  %-28s %s\n", keys, a.Desc)
+	}
+	return b.String()
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"math"
-	"net/http"
 	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -17,7 +20,7 @@ const (
 	shardsNeeded = 450
 	shaleNeeded  = 2520
 	cacheFile    = "prices_cache.json"
-	cacheTTL     = 20 * time.Minute
+	defaultTTL   = 20 * time.Minute
 
 	version = "v1.0.0"
 )
@@ -44,62 +47,184 @@ type ArmorOption struct {
 }
 
 type AppState struct {
-	Shale     PriceTriple   `json:"shale"`
-	Shard     PriceTriple   `json:"shard"`
-	Armors    []ArmorOption `json:"armors"`
-	FetchedAt time.Time     `json:"fetched_at"`
-	Mode      string        `json:"mode"` // "api" or "manual"
+	Shale     PriceTriple     `json:"shale"`
+	Shard     PriceTriple     `json:"shard"`
+	Armors    []ArmorOption   `json:"armors"`
+	Overrides []PriceOverride `json:"overrides,omitempty"`
+	FetchedAt time.Time       `json:"fetched_at"`
+	Mode      string          `json:"mode"`            // "api" or "manual"
+	Stale     []string        `json:"stale,omitempty"` // targets whose last fetch failed; price is from an earlier one
 }
 
-type CacheFile struct {
-	State AppState `json:"state"`
+// PriceOverride is one manually entered value, kept apart from the fetched
+// prices and applied on top of them by ComputeReport.
+type PriceOverride struct {
+	Target    string    `json:"target"`    // shale | shard | armor1..3
+	Component string    `json:"component"` // high | low | avg
+	Value     int64     `json:"value"`
+	SetAt     time.Time `json:"set_at"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"` // zero = never
 }
 
 type ProfitCase struct {
-	SaleLabel   string // "low" | "avg" | "high"
-	SalePrice   int64
-	TaxPaid     int64
-	NetAfterTax int64
-	Profit      int64
+	SaleLabel   string `json:"sale_label"` // "low" | "avg" | "high"
+	SalePrice   int64  `json:"sale_price"`
+	TaxPaid     int64  `json:"tax_paid"`
+	NetAfterTax int64  `json:"net_after_tax"`
+	Profit      int64  `json:"profit"`
 }
 
 type ArmorReport struct {
-	Name     string
-	ItemID   int
-	Sale     PriceTriple
-	Cases    []ProfitCase
-	BestCase ProfitCase
+	Slot     string       `json:"slot"` // "armor1".. as used by ApplyManualSet
+	Name     string       `json:"name"`
+	ItemID   int          `json:"item_id"`
+	Sale     PriceTriple  `json:"sale"`
+	Cases    []ProfitCase `json:"cases"`
+	BestCase ProfitCase   `json:"best_case"`
 }
 
 type Report struct {
-	Version    string
-	Mode       string
-	FetchedAt  time.Time
-	CacheAge   time.Duration
-	CacheFresh bool
-
-	Shale PriceTriple
-	Shard PriceTriple
+	Version    string        `json:"version"`
+	Mode       string        `json:"mode"`
+	FetchedAt  time.Time     `json:"fetched_at"`
+	CacheAge   time.Duration `json:"cache_age_ns"`
+	CacheFresh bool          `json:"cache_fresh"`
+
+	Shale PriceTriple `json:"shale"`
+	Shard PriceTriple `json:"shard"`
+
+	Overrides []PriceOverride `json:"overrides,omitempty"` // active, already applied
+	Stale     []string        `json:"stale,omitempty"`     // targets kept from an earlier fetch
+
+	Profile   string          `json:"profile"`
+	TaxBps    int64           `json:"tax_bps"`
+	SaleBasis string          `json:"sale_basis"`
+	Recipe    Recipe          `json:"recipe"`
+	Inventory InventoryReport `json:"inventory"`
+
+	IngredientCost  PriceTriple   `json:"ingredient_cost"`
+	Armors          []ArmorReport `json:"armors"`
+	BestByAvgProfit ArmorReport   `json:"best_by_avg_profit"`
+	BestByHighSale  ArmorReport   `json:"best_by_high_sale"`
+	BestForBasis    ArmorReport   `json:"best_for_basis"` // best at the profile's sale basis
+
+	SortBy string `json:"sort_by,omitempty"` // armor order in renders, see sortKeys
+}
 
-	IngredientCost  PriceTriple
-	Armors          []ArmorReport
-	BestByAvgProfit ArmorReport
-	BestByHighSale  ArmorReport
+// InventoryReport says how far the profile's stock and cash go.
+type InventoryReport struct {
+	Have          Inventory `json:"have"`
+	FromStock     int64     `json:"from_stock"`      // pieces craftable from owned ingredients alone
+	NextCraftCost int64     `json:"next_craft_cost"` // avg gp to buy what the next piece is missing
+	Affordable    int64     `json:"affordable"`      // pieces craftable from stock plus cash
 }
 
 func main() {
-	fmt.Printf("OathPlate Calculator %s\n", version)
-
-	state := defaultState()
-	if c, ok := loadCache(); ok {
-		state = c.State
+	profileFlag := flag.String("profile", defaultProfileName, "profile to use (see: profile list)")
+	cacheDirFlag := flag.String("cache-dir", "", "directory for the price cache and history (default: user cache dir)")
+	ttl := flag.Duration("ttl", defaultTTL, "how long fetched prices count as fresh")
+	fetchIfStale := flag.Bool("fetch-if-stale", false, "TUI: fetch on startup when the cache is missing or stale")
+	autoRefresh := flag.Duration("auto-refresh", 0, "TUI: fetch automatically on this interval (minimum 1m, 0 = off)")
+	noMouse := flag.Bool("no-mouse", false, "TUI: leave the mouse to the terminal (e.g. for selecting text)")
+	retries := flag.Int("retries", fetchRetries, "retries per item on timeouts, 429 and 5xx")
+	retryWait := flag.Duration("retry-wait", fetchRetryWait, "first retry backoff, doubled per retry with jitter")
+	partial := flag.Bool("partial", fetchPartial, "keep earlier prices (marked stale) for items whose fetch failed")
+	offline := flag.Bool("offline", false, "never use the network; replay wiki responses from the fixtures directory")
+	record := flag.Bool("record", false, "save every wiki response to the fixtures directory")
+	fixturesFlag := flag.String("fixtures", "", "fixtures directory for -offline and -record (default: fixtures/ in the cache dir)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if err := initPaths(*cacheDirFlag); err != nil {
+		fmt.Println("CACHE DIR ERROR:", err)
+		os.Exit(1)
+	}
+	// before the profile: offline runs use their own cache directory
+	if err := initFixtures(*offline, *record, *fixturesFlag); err != nil {
+		fmt.Println("FIXTURES ERROR:", err)
+		os.Exit(1)
+	}
+	if err := selectProfile(*profileFlag); err != nil {
+		fmt.Println("PROFILE ERROR:", err)
+		os.Exit(1)
+	}
+	if fixtures == fixturesReplay {
+		if err := seedOfflineCache(); err != nil {
+			fmt.Println("FIXTURES ERROR:", err)
+		}
 	}
+	if *ttl > 0 {
+		cacheTTL = *ttl
+	}
+	fetchRetries, fetchRetryWait, fetchPartial = max(*retries, 0), *retryWait, *partial
+
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		fmt.Printf("OathPlate Calculator %s\n", version)
+
+		opts := TUIOptions{AutoRefresh: *autoRefresh, FetchIfStale: *fetchIfStale, NoMouse: *noMouse}
+		if fixtures == fixturesReplay {
+			opts.Notice = "Offline: fetches replay the fixtures in " + fixturesDir
+		}
+		state, err := loadCachedState()
+		if err != nil {
+			opts.Notice = fmt.Sprintf("[red]Cache not loaded[-]: %v", err)
+		}
 
-	if err := RunTUI(state); err != nil {
-		fmt.Println("TUI ERROR:", err)
+		if err := RunTUI(state, opts); err != nil {
+			fmt.Println("TUI ERROR:", err)
+		}
+	case "watch":
+		if err := runWatch(flag.Args()[1:]); err != nil {
+			fmt.Println("WATCH ERROR:", err)
+			os.Exit(1)
+		}
+	case "serve":
+		if err := runServe(flag.Args()[1:]); err != nil {
+			fmt.Println("SERVE ERROR:", err)
+			os.Exit(1)
+		}
+	case "export":
+		if err := runExport(flag.Args()[1:]); err != nil {
+			fmt.Println("EXPORT ERROR:", err)
+			os.Exit(1)
+		}
+	case "scenario":
+		if err := runScenario(flag.Args()[1:]); err != nil {
+			fmt.Println("SCENARIO ERROR:", err)
+			os.Exit(1)
+		}
+	case "profile":
+		if err := runProfile(flag.Args()[1:]); err != nil {
+			fmt.Println("PROFILE ERROR:", err)
+			os.Exit(1)
+		}
+	case "post":
+		if err := runPost(flag.Args()[1:]); err != nil {
+			fmt.Println("POST ERROR:", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("unknown command %q\n", cmd)
+		usage()
+		os.Exit(2)
 	}
 }
 
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Usage:")
+	fmt.Fprintln(out, "  oathplateCalculator [flags]            start the TUI")
+	fmt.Fprintln(out, "  oathplateCalculator watch [-interval]  poll prices in the background")
+	fmt.Fprintln(out, "  oathplateCalculator serve [-addr]      serve reports over HTTP")
+	fmt.Fprintln(out, "  oathplateCalculator post [-webhook]    post the report to Discord/Slack")
+	fmt.Fprintln(out, "  oathplateCalculator export html|csv    write an HTML or CSV report [-o file]")
+	fmt.Fprintln(out, "  oathplateCalculator scenario ...       save, list and compare what-if scenarios")
+	fmt.Fprintln(out, "  oathplateCalculator profile ...        list, show and create profiles")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
 func defaultState() AppState {
 	return AppState{
 		Armors: []ArmorOption{
@@ -115,6 +240,18 @@ func defaultState() AppState {
    FETCH (API) → STATE
 */
 
+// PriceSource produces a freshly priced AppState. prev is the state it
+// replaces, whose prices a partial fetch keeps for failed items. The wiki
+// API is the production source; watch and serve take one so they share a
+// code path.
+type PriceSource interface {
+	Fetch(prev AppState) (AppState, error)
+}
+
+type wikiSource struct{}
+
+func (wikiSource) Fetch(prev AppState) (AppState, error) { return FetchStateFromAPI(prev) }
+
 type latestResponse struct {
 	Data map[string]struct {
 		High *int64 `json:"high"`
@@ -122,108 +259,167 @@ type latestResponse struct {
 	} `json:"data"`
 }
 
-func FetchStateFromAPI() (AppState, error) {
-	ids := []int{itemIDShale, itemIDShard, armorID1, armorID2, armorID3}
-	for _, id := range ids {
-		if id == 0 {
-			return AppState{}, errors.New("set item IDs first (shale/shard/armor1/armor2/armor3)")
-		}
+func FetchStateFromAPI(prev AppState) (AppState, error) {
+	return FetchStateFromAPIContext(context.Background(), prev, nil)
+}
+
+// fetchItem is one item a fetch prices; Name is the field target.
+type fetchItem struct {
+	Name string
+	ID   int
+}
+
+// fetchItems are the items a fetch prices, in order.
+func fetchItems() []fetchItem {
+	return []fetchItem{
+		{"shale", itemIDShale},
+		{"shard", itemIDShard},
+		{"armor1", armorID1},
+		{"armor2", armorID2},
+		{"armor3", armorID3},
 	}
+}
 
-	shale, err := fetchLatestTriple(itemIDShale)
-	if err != nil {
-		return AppState{}, fmt.Errorf("shale fetch: %w", err)
+// FetchProgress is reported after each item of a fetch.
+type FetchProgress struct {
+	Item  string
+	Done  int // items finished so far, failed or not
+	Total int
+	Err   error // *FetchError when this item failed
+}
+
+// FetchError is one failed item request, with the HTTP status (0 when there
+// was no response), how many attempts were made and how long they took.
+type FetchError struct {
+	Item     string
+	ItemID   int
+	Status   int
+	Attempts int
+	Took     time.Duration
+	Err      error
+}
+
+func (e *FetchError) Error() string { return fmt.Sprintf("%s fetch: %v", e.Item, e.Err) }
+func (e *FetchError) Unwrap() error { return e.Err }
+
+// fetchPartial keeps the earlier price of an item whose fetch failed, marked
+// stale, instead of failing the whole fetch (-partial).
+var fetchPartial = false
+
+// FetchStateFromAPIContext fetches every item. progress, if not nil, is
+// called after each one. A cancelled ctx stops the fetch, and without
+// fetchPartial so does the first failure. With fetchPartial, failed items
+// keep their price from prev and are listed in Stale; the fetch only fails
+// when nothing could be fetched or prev has no price for a failed item.
+func FetchStateFromAPIContext(ctx context.Context, prev AppState, progress func(FetchProgress)) (AppState, error) {
+	items := fetchItems()
+	for _, it := range items {
+		if it.ID == 0 {
+			return AppState{}, errors.New("set item IDs first (shale/shard/armor1/armor2/armor3)")
+		}
 	}
-	shard, err := fetchLatestTriple(itemIDShard)
-	if err != nil {
-		return AppState{}, fmt.Errorf("shard fetch: %w", err)
+	report := func(p FetchProgress) {
+		if progress != nil {
+			progress(p)
+		}
 	}
 
-	a1, err := fetchLatestTriple(armorID1)
-	if err != nil {
-		return AppState{}, fmt.Errorf("armor1 fetch: %w", err)
+	st := defaultState()
+	st.Mode = "api"
+	if fixtures == fixturesReplay {
+		st.Mode = "offline" // made-up prices, cached apart (useProfileDir)
+	}
+	var failed []*FetchError
+	for i, it := range items {
+		start := time.Now()
+		t, res, err := fetchLatestTriple(ctx, it.ID)
+		if err != nil {
+			ferr := &FetchError{Item: it.Name, ItemID: it.ID, Status: res.Status, Attempts: res.Attempts, Took: time.Since(start), Err: err}
+			report(FetchProgress{Item: it.Name, Done: i + 1, Total: len(items), Err: ferr})
+			if ctx.Err() != nil || !fetchPartial {
+				return AppState{}, ferr
+			}
+			failed = append(failed, ferr)
+			continue
+		}
+		dst, _ := fieldTriple(&st, it.Name)
+		*dst = t
+		report(FetchProgress{Item: it.Name, Done: i + 1, Total: len(items)})
 	}
-	a2, err := fetchLatestTriple(armorID2)
-	if err != nil {
-		return AppState{}, fmt.Errorf("armor2 fetch: %w", err)
+
+	if len(failed) == len(items) {
+		return AppState{}, failed[0]
 	}
-	a3, err := fetchLatestTriple(armorID3)
-	if err != nil {
-		return AppState{}, fmt.Errorf("armor3 fetch: %w", err)
+	for _, f := range failed {
+		old, err := fieldTriple(&prev, f.Item)
+		if err != nil || *old == (PriceTriple{}) {
+			return AppState{}, fmt.Errorf("%w (no earlier price to keep)", f)
+		}
+		dst, _ := fieldTriple(&st, f.Item)
+		*dst = *old
+		st.Stale = append(st.Stale, f.Item)
 	}
-
-	return AppState{
-		Shale: shale,
-		Shard: shard,
-		Armors: []ArmorOption{
-			{Name: "Oathplate Helmet", ItemID: armorID1, Price: a1},
-			{Name: "Oathplate Chestplate", ItemID: armorID2, Price: a2},
-			{Name: "Oathplate Legs", ItemID: armorID3, Price: a3},
-		},
-		FetchedAt: time.Now(),
-		Mode:      "api",
-	}, nil
+	st.FetchedAt = time.Now()
+	return st, nil
 }
 
-func fetchLatestTriple(id int) (PriceTriple, error) {
+func fetchLatestTriple(ctx context.Context, id int) (PriceTriple, wikiResponse, error) {
 	url := fmt.Sprintf("https://prices.runescape.wiki/api/v1/osrs/latest?id=%d", id)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return PriceTriple{}, err
-	}
-	req.Header.Set("User-Agent", "oathplate-calculator/1.0 (manual refresh)")
-
-	resp, err := client.Do(req)
+	res, err := wikiGet(ctx, url, 10*time.Second)
 	if err != nil {
-		return PriceTriple{}, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return PriceTriple{}, fmt.Errorf("bad status: %s", resp.Status)
+		return PriceTriple{}, res, err
 	}
 
 	var out latestResponse
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return PriceTriple{}, err
+	if err := json.Unmarshal(res.Body, &out); err != nil {
+		return PriceTriple{}, res, err
 	}
 
 	key := strconv.Itoa(id)
 	row, ok := out.Data[key]
 	if !ok || row.High == nil || row.Low == nil {
-		return PriceTriple{}, fmt.Errorf("missing high/low for id=%d", id)
+		return PriceTriple{}, res, fmt.Errorf("missing high/low for id=%d", id)
 	}
 
 	avg := (*row.High + *row.Low) / 2
-	return PriceTriple{High: *row.High, Low: *row.Low, Avg: avg}, nil
+	return PriceTriple{High: *row.High, Low: *row.Low, Avg: avg}, res, nil
 }
 
 /*
    COMPUTE (pure) → REPORT
 */
 
+// ComputeReport computes with the active profile.
 func ComputeReport(state AppState) Report {
-	var age time.Duration
-	if !state.FetchedAt.IsZero() {
-		age = time.Since(state.FetchedAt)
-	}
-	fresh := !state.FetchedAt.IsZero() && age <= cacheTTL
+	return ComputeReportFor(state, activeProfile)
+}
+
+func ComputeReportFor(state AppState, p Profile) Report {
+	age, fresh := stateAge(state)
+	overrides := activeOverrides(state, time.Now())
+	state = effectiveState(state, time.Now())
 
+	shaleQty, shardQty := p.Recipe.Shale, p.Recipe.Shards
 	ingredientCost := PriceTriple{
-		Low:  int64(shaleNeeded)*state.Shale.Low + int64(shardsNeeded)*state.Shard.Low,
-		Avg:  int64(shaleNeeded)*state.Shale.Avg + int64(shardsNeeded)*state.Shard.Avg,
-		High: int64(shaleNeeded)*state.Shale.High + int64(shardsNeeded)*state.Shard.High,
+		Low:  shaleQty*state.Shale.Low + shardQty*state.Shard.Low,
+		Avg:  shaleQty*state.Shale.Avg + shardQty*state.Shard.Avg,
+		High: shaleQty*state.Shale.High + shardQty*state.Shard.High,
 	}
 
 	armorReports := make([]ArmorReport, 0, len(state.Armors))
-	for _, a := range state.Armors {
-		armorReports = append(armorReports, computeArmor(a, ingredientCost))
+	for i, a := range state.Armors {
+		if !p.wantsArmor(a.ItemID) {
+			continue
+		}
+		ar := computeArmor(a, ingredientCost, p)
+		ar.Slot = fmt.Sprintf("armor%d", i+1)
+		armorReports = append(armorReports, ar)
 	}
 
 	bestByAvg := pickBestByAvgProfit(armorReports)
 	bestByHighSale := pickBestByHighSale(armorReports)
+	bestForBasis := pickBestByProfit(armorReports, p.SaleBasis)
 
 	return Report{
 		Version:         version,
@@ -233,18 +429,56 @@ func ComputeReport(state AppState) Report {
 		CacheFresh:      fresh,
 		Shale:           state.Shale,
 		Shard:           state.Shard,
+		Overrides:       overrides,
+		Stale:           state.Stale,
 		IngredientCost:  ingredientCost,
 		Armors:          armorReports,
 		BestByAvgProfit: bestByAvg,
 		BestByHighSale:  bestByHighSale,
+		BestForBasis:    bestForBasis,
+		Profile:         p.Name,
+		TaxBps:          p.TaxBps,
+		SaleBasis:       p.SaleBasis,
+		Recipe:          p.Recipe,
+		Inventory:       computeInventory(p, state),
+	}
+}
+
+// computeInventory works out crafts from stock, the avg cost of topping up
+// for the next piece, and how many pieces cash stretches to after that.
+func computeInventory(p Profile, state AppState) InventoryReport {
+	inv := p.Inventory
+	r := InventoryReport{Have: inv}
+	needShale, needShards := p.Recipe.Shale, p.Recipe.Shards
+	if needShale <= 0 && needShards <= 0 {
+		return r
+	}
+
+	r.FromStock = math.MaxInt64
+	if needShale > 0 {
+		r.FromStock = min(r.FromStock, inv.Shale/needShale)
+	}
+	if needShards > 0 {
+		r.FromStock = min(r.FromStock, inv.Shards/needShards)
+	}
+
+	leftShale := inv.Shale - r.FromStock*needShale
+	leftShards := inv.Shards - r.FromStock*needShards
+	r.NextCraftCost = max(needShale-leftShale, 0)*state.Shale.Avg + max(needShards-leftShards, 0)*state.Shard.Avg
+
+	r.Affordable = r.FromStock
+	full := needShale*state.Shale.Avg + needShards*state.Shard.Avg
+	if inv.Cash >= r.NextCraftCost && full > 0 {
+		r.Affordable += 1 + (inv.Cash-r.NextCraftCost)/full
 	}
+	return r
 }
 
-func computeArmor(a ArmorOption, ingredientCost PriceTriple) ArmorReport {
+func computeArmor(a ArmorOption, ingredientCost PriceTriple, p Profile) ArmorReport {
 	cases := []ProfitCase{
-		computeCase("low", a.Price.Low, ingredientCost.Low),
-		computeCase("avg", a.Price.Avg, ingredientCost.Avg),
-		computeCase("high", a.Price.High, ingredientCost.High),
+		computeCase("low", a.Price.Low, ingredientCost.Low, p),
+		computeCase("avg", a.Price.Avg, ingredientCost.Avg, p),
+		computeCase("high", a.Price.High, ingredientCost.High, p),
 	}
 
 	best := cases[0]
@@ -263,8 +497,8 @@ func computeArmor(a ArmorOption, ingredientCost PriceTriple) ArmorReport {
 	}
 }
 
-func computeCase(label string, salePrice int64, ingredientCost int64) ProfitCase {
-	taxPaid := (salePrice * 2) / 100
+func computeCase(label string, salePrice int64, ingredientCost int64, p Profile) ProfitCase {
+	taxPaid := p.tax(salePrice)
 	net := salePrice - taxPaid
 	profit := net - ingredientCost
 
@@ -278,16 +512,20 @@ func computeCase(label string, salePrice int64, ingredientCost int64) ProfitCase
 }
 
 func pickBestByAvgProfit(armors []ArmorReport) ArmorReport {
+	return pickBestByProfit(armors, "avg")
+}
+
+func pickBestByProfit(armors []ArmorReport, label string) ArmorReport {
 	if len(armors) == 0 {
 		return ArmorReport{}
 	}
 	best := armors[0]
-	bestAvgProfit := profitForLabel(best, "avg")
+	bestProfit := profitForLabel(best, label)
 	for _, a := range armors[1:] {
-		p := profitForLabel(a, "avg")
-		if p > bestAvgProfit {
+		p := profitForLabel(a, label)
+		if p > bestProfit {
 			best = a
-			bestAvgProfit = p
+			bestProfit = p
 		}
 	}
 	return best
@@ -306,6 +544,23 @@ func pickBestByHighSale(armors []ArmorReport) ArmorReport {
 	return best
 }
 
+// IsManual reports whether target.component ("shard", "low") in this report
+// came from a manual override rather than a fetch.
+func (r Report) IsManual(target, component string) bool {
+	for _, o := range r.Overrides {
+		if o.Target == target && o.Component == component {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStale reports whether target's price was kept from an earlier fetch
+// because the last one failed for it.
+func (r Report) IsStale(target string) bool {
+	return slices.Contains(r.Stale, target)
+}
+
 func profitForLabel(a ArmorReport, label string) int64 {
 	for _, c := range a.Cases {
 		if c.SaleLabel == label {
@@ -315,6 +570,54 @@ func profitForLabel(a ArmorReport, label string) int64 {
 	return math.MinInt64
 }
 
+// roi is profit as a fraction of the ingredient cost (net - profit).
+func (c ProfitCase) roi() float64 {
+	cost := c.NetAfterTax - c.Profit
+	if cost <= 0 {
+		return 0
+	}
+	return float64(c.Profit) / float64(cost)
+}
+
+func roiForLabel(a ArmorReport, label string) float64 {
+	for _, c := range a.Cases {
+		if c.SaleLabel == label {
+			return c.roi()
+		}
+	}
+	return math.Inf(-1)
+}
+
+// sortKeys are the armor orderings renders understand; the first is the
+// default.
+var sortKeys = []string{"profit", "roi", "sale", "name"}
+
+// sortArmors orders armors best first by key at the given sale basis.
+func sortArmors(armors []ArmorReport, key, basis string) {
+	sort.SliceStable(armors, func(i, j int) bool {
+		a, b := armors[i], armors[j]
+		switch key {
+		case "roi":
+			return roiForLabel(a, basis) > roiForLabel(b, basis)
+		case "sale":
+			return salePriceForLabel(a, basis) > salePriceForLabel(b, basis)
+		case "name":
+			return a.Name < b.Name
+		default:
+			return profitForLabel(a, basis) > profitForLabel(b, basis)
+		}
+	})
+}
+
+func salePriceForLabel(a ArmorReport, label string) int64 {
+	for _, c := range a.Cases {
+		if c.SaleLabel == label {
+			return c.SalePrice
+		}
+	}
+	return 0
+}
+
 /*
    RENDER (string)
 */
@@ -336,53 +639,103 @@ func RenderReportString(r Report) string {
 	} else {
 		w("Mode: %s\n", r.Mode)
 	}
+	if r.Profile != "" && r.Profile != defaultProfileName {
+		w("Profile: %s | Tax: %s | Sale basis: %s\n", r.Profile, formatBps(r.TaxBps), r.SaleBasis)
+	}
+	if len(r.Stale) > 0 {
+		w("Stale: %s (last fetch failed, earlier prices kept)\n", strings.Join(r.Stale, ", "))
+	}
 
 	b.WriteString(strings.Repeat("-", 64) + "\n")
 
+	// manual values carry a trailing '*', live ones a space to keep columns aligned
+	px := func(target string, t PriceTriple) (string, string, string) {
+		mark := func(comp string, v int64) string {
+			return comma(v) + boolWord(r.IsManual(target, comp), "*", " ")
+		}
+		return mark("high", t.High), mark("low", t.Low), mark("avg", t.Avg)
+	}
+
 	b.WriteString("PRICES (high / low / avg)\n")
-	w("  Infernal Shale:   %12s / %12s / %12s gp\n", comma(r.Shale.High), comma(r.Shale.Low), comma(r.Shale.Avg))
-	w("  Oathplate Shards: %12s / %12s / %12s gp\n", comma(r.Shard.High), comma(r.Shard.Low), comma(r.Shard.Avg))
+	hi, lo, av := px("shale", r.Shale)
+	w("  Infernal Shale:   %13s / %13s / %13s gp\n", hi, lo, av)
+	hi, lo, av = px("shard", r.Shard)
+	w("  Oathplate Shards: %13s / %13s / %13s gp\n", hi, lo, av)
 	b.WriteString("\n")
 
-	b.WriteString("INGREDIENT COST (using shale+shards high/low/avg)\n")
+	w("INGREDIENT COST (%s shale + %s shards, using high/low/avg)\n", comma(r.Recipe.Shale), comma(r.Recipe.Shards))
 	w("  Cost low:  %s gp\n", comma(r.IngredientCost.Low))
 	w("  Cost avg:  %s gp\n", comma(r.IngredientCost.Avg))
 	w("  Cost high: %s gp\n", comma(r.IngredientCost.High))
+	if inv := r.Inventory; inv.Have != (Inventory{}) {
+		w("  Stock: %s shale, %s shards, %s gp cash\n", comma(inv.Have.Shale), comma(inv.Have.Shards), comma(inv.Have.Cash))
+		w("  Craftable from stock: %d | next piece needs %s gp | affordable: %d\n",
+			inv.FromStock, comma(inv.NextCraftCost), inv.Affordable)
+	}
 	b.WriteString(strings.Repeat("-", 64) + "\n")
 
 	armors := append([]ArmorReport(nil), r.Armors...)
-	sort.Slice(armors, func(i, j int) bool {
-		return profitForLabel(armors[i], "avg") > profitForLabel(armors[j], "avg")
-	})
+	basis := cmp.Or(r.SaleBasis, "avg")
+	sortBy := cmp.Or(r.SortBy, sortKeys[0])
+	sortArmors(armors, sortBy, basis)
 
 	b.WriteString("ARMOR OPTIONS (sale high / low / avg) + profit using matching ingredient cost tier\n")
+	if sortBy != sortKeys[0] {
+		w("  (sorted by %s @ %s)\n", sortBy, basis)
+	}
 	for _, a := range armors {
 		w("\n  %s\n", a.Name)
-		w("    Sale:  %12s / %12s / %12s gp\n", comma(a.Sale.High), comma(a.Sale.Low), comma(a.Sale.Avg))
+		hi, lo, av := px(a.Slot, a.Sale)
+		w("    Sale:  %13s / %13s / %13s gp\n", hi, lo, av)
 		for _, c := range a.Cases {
 			sign := ""
 			if c.Profit < 0 {
 				sign = "-"
 			}
-			w("    Profit @ %-4s sale: %s%s gp (tax %s, net %s)\n",
+			w("    Profit @ %-4s sale: %s%s gp (tax %s, net %s, roi %.1f%%)\n",
 				c.SaleLabel,
 				sign, comma(abs(c.Profit)),
 				comma(c.TaxPaid),
 				comma(c.NetAfterTax),
+				c.roi()*100,
 			)
 		}
 	}
 
 	b.WriteString("\n" + strings.Repeat("-", 64) + "\n")
 	b.WriteString("RECOMMENDATION\n")
-	w("  Best by AVG profit: %s (avg profit %s gp)\n",
-		r.BestByAvgProfit.Name,
-		comma(profitForLabel(r.BestByAvgProfit, "avg")),
-	)
-	w("  Highest sale HIGH:  %s (high sale %s gp)\n",
-		r.BestByHighSale.Name,
-		comma(r.BestByHighSale.Sale.High),
-	)
+	if len(r.Armors) == 0 {
+		w("  No armors to compare (check the profile's armor list).\n")
+	} else {
+		w("  Best by AVG profit: %s (avg profit %s gp)\n",
+			r.BestByAvgProfit.Name,
+			comma(profitForLabel(r.BestByAvgProfit, "avg")),
+		)
+		w("  Highest sale HIGH:  %s (high sale %s gp)\n",
+			r.BestByHighSale.Name,
+			comma(r.BestByHighSale.Sale.High),
+		)
+	}
+	if basis != "avg" && len(r.Armors) > 0 {
+		w("  Best @ %-4s sale:   %s (%s profit %s gp)\n",
+			basis,
+			r.BestForBasis.Name,
+			basis,
+			comma(profitForLabel(r.BestForBasis, basis)),
+		)
+	}
+
+	if len(r.Overrides) > 0 {
+		b.WriteString(strings.Repeat("-", 64) + "\n")
+		b.WriteString("MANUAL OVERRIDES (* above)\n")
+		for _, o := range r.Overrides {
+			w("  %-12s %12s gp  set %s", o.Target+"."+o.Component, comma(o.Value), o.SetAt.Local().Format("15:04:05"))
+			if !o.ExpiresAt.IsZero() {
+				w(", expires %s", o.ExpiresAt.Local().Format("15:04:05"))
+			}
+			b.WriteString("\n")
+		}
+	}
 	b.WriteString(strings.Repeat("=", 64) + "\n")
 
 	return b.String()
@@ -392,73 +745,148 @@ func RenderReportString(r Report) string {
    MANUAL SET
 */
 
+// ApplyManualSet records a manual override for field ("shale", "shard.low",
+// "armor2.avg", ...). Fetched prices are left untouched; overrides are layered
+// on top at compute time. A bare target sets high, low and avg.
 func ApplyManualSet(state *AppState, field string, val int64) error {
-	parts := strings.Split(field, ".")
-	target := parts[0]
-	component := ""
-	if len(parts) == 2 {
-		component = parts[1]
-	} else if len(parts) > 2 {
-		return errors.New("invalid field format")
+	return ApplyManualSetUntil(state, field, val, time.Time{})
+}
+
+// ApplyManualSetUntil is ApplyManualSet with an expiry. A zero expires never
+// expires.
+func ApplyManualSetUntil(state *AppState, field string, val int64, expires time.Time) error {
+	target, components, err := parseField(state, field)
+	if err != nil {
+		return err
 	}
+	now := time.Now()
+	for _, c := range components {
+		ClearOverride(state, target+"."+c)
+		state.Overrides = append(state.Overrides, PriceOverride{
+			Target:    target,
+			Component: c,
+			Value:     val,
+			SetAt:     now,
+			ExpiresAt: expires,
+		})
+	}
+	return nil
+}
 
-	setTriple := func(t *PriceTriple) error {
-		if component == "" {
-			t.High, t.Low, t.Avg = val, val, val
-			return nil
+// ClearOverride drops the overrides for field; an empty field clears all.
+// It reports how many were removed.
+func ClearOverride(state *AppState, field string) int {
+	if field == "" {
+		n := len(state.Overrides)
+		state.Overrides = nil
+		return n
+	}
+	target, component, _ := strings.Cut(field, ".")
+	target = canonicalTarget(target)
+	var kept []PriceOverride
+	for _, o := range state.Overrides {
+		if o.Target == target && (component == "" || o.Component == component) {
+			continue
 		}
-		switch component {
-		case "high":
-			t.High = val
-		case "low":
-			t.Low = val
-		case "avg":
-			t.Avg = val
+		kept = append(kept, o)
+	}
+	n := len(state.Overrides) - len(kept)
+	state.Overrides = kept
+	return n
+}
+
+// parseField validates a field name and expands it to its components.
+func parseField(state *AppState, field string) (string, []string, error) {
+	parts := strings.Split(field, ".")
+	target := canonicalTarget(parts[0])
+	components := []string{"high", "low", "avg"}
+	if len(parts) == 2 {
+		switch parts[1] {
+		case "high", "low", "avg":
+			components = []string{parts[1]}
 		default:
-			return fmt.Errorf("unknown component %q (use high|low|avg)", component)
+			return "", nil, fmt.Errorf("unknown component %q (use high|low|avg)", parts[1])
 		}
-		return nil
+	} else if len(parts) > 2 {
+		return "", nil, errors.New("invalid field format")
 	}
 
+	if _, err := fieldTriple(state, target); err != nil {
+		return "", nil, err
+	}
+	return target, components, nil
+}
+
+// targetAliases lets people type armor names instead of slot numbers.
+var targetAliases = map[string]string{
+	"helmet": "armor1", "helm": "armor1",
+	"chest": "armor2", "chestplate": "armor2",
+	"legs": "armor3",
+}
+
+func canonicalTarget(name string) string {
+	if t, ok := targetAliases[name]; ok {
+		return t
+	}
+	return name
+}
+
+// fieldTriple resolves a target name to the price triple it addresses.
+func fieldTriple(state *AppState, target string) (*PriceTriple, error) {
 	switch target {
 	case "shale":
-		return setTriple(&state.Shale)
+		return &state.Shale, nil
 	case "shard":
-		return setTriple(&state.Shard)
+		return &state.Shard, nil
 	case "armor1", "armor2", "armor3":
 		idx := map[string]int{"armor1": 0, "armor2": 1, "armor3": 2}[target]
 		if len(state.Armors) < 3 {
-			return errors.New("armor list not initialized")
+			return nil, errors.New("armor list not initialized")
 		}
-		return setTriple(&state.Armors[idx].Price)
+		return &state.Armors[idx].Price, nil
 	default:
-		return errors.New("unknown field (use shale, shard, armor1, armor2, armor3)")
+		return nil, errors.New("unknown field (use shale, shard, armor1..3 or helmet, chest, legs)")
 	}
 }
 
-/*
-   CACHE
-*/
-
-func loadCache() (CacheFile, bool) {
-	b, err := os.ReadFile(cacheFile)
-	if err != nil {
-		return CacheFile{}, false
-	}
-	var c CacheFile
-	if err := json.Unmarshal(b, &c); err != nil {
-		return CacheFile{}, false
+// activeOverrides returns the overrides that have not expired at now.
+func activeOverrides(state AppState, now time.Time) []PriceOverride {
+	var out []PriceOverride
+	for _, o := range state.Overrides {
+		if o.ExpiresAt.IsZero() || now.Before(o.ExpiresAt) {
+			out = append(out, o)
+		}
 	}
-	return c, true
+	return out
 }
 
-func saveCache(state AppState) error {
-	c := CacheFile{State: state}
-	b, err := json.MarshalIndent(c, "", "  ")
-	if err != nil {
-		return err
+// effectiveState returns a copy of state with active overrides applied to
+// the fetched prices. The input is not modified.
+func effectiveState(state AppState, now time.Time) AppState {
+	eff := state
+	eff.Armors = append([]ArmorOption(nil), state.Armors...)
+	for _, o := range activeOverrides(state, now) {
+		t, err := fieldTriple(&eff, o.Target)
+		if err != nil {
+			continue
+		}
+		switch o.Component {
+		case "high":
+			t.High = o.Value
+		case "low":
+			t.Low = o.Value
+		case "avg":
+			t.Avg = o.Value
+		}
 	}
-	return os.WriteFile(cacheFile, b, 0o644)
+	return eff
+}
+
+// withOverridesFrom carries prev's unexpired overrides onto freshly fetched
+// prices, so a fetch never wipes manual values.
+func withOverridesFrom(fetched, prev AppState) AppState {
+	fetched.Overrides = activeOverrides(prev, time.Now())
+	return fetched
 }
 
 /*
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchStateFromAPIContextOffline(t *testing.T) {
+	if err := initFixtures(true, false, filepath.Join("testdata", "fixtures")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fixtures, fixturesDir = fixturesOff, "" })
+
+	var done []string
+	st, err := FetchStateFromAPIContext(context.Background(), AppState{}, func(p FetchProgress) {
+		if p.Err != nil {
+			t.Errorf("%s: %v", p.Item, p.Err)
+		}
+		done = append(done, p.Item)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st.Mode != "offline" {
+		t.Errorf("Mode = %q, want offline", st.Mode)
+	}
+	if st.FetchedAt.IsZero() {
+		t.Error("FetchedAt not set")
+	}
+	if len(st.Stale) > 0 {
+		t.Errorf("Stale = %v, want none", st.Stale)
+	}
+	if len(done) != len(fetchItems()) {
+		t.Errorf("progress for %v, want every item", done)
+	}
+
+	want := map[string]PriceTriple{
+		"shale":  {High: 4900, Low: 4700, Avg: 4800},
+		"shard":  {High: 29500, Low: 28100, Avg: 28800},
+		"armor1": {High: 41200000, Low: 40100000, Avg: 40650000},
+		"armor2": {High: 62500000, Low: 61000000, Avg: 61750000},
+		"armor3": {High: 52900000, Low: 51800000, Avg: 52350000},
+	}
+	for target, w := range want {
+		got, err := fieldTriple(&st, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != w {
+			t.Errorf("%s = %+v, want %+v", target, *got, w)
+		}
+	}
+}
+
+func TestFetchStateFromAPIContextOfflineMissingFixture(t *testing.T) {
+	if err := initFixtures(true, false, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fixtures, fixturesDir = fixturesOff, "" })
+
+	_, err := FetchStateFromAPIContext(context.Background(), AppState{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "no fixture") {
+		t.Fatalf("err = %v, want a missing fixture error", err)
+	}
+}
+
+func TestOfflineCacheIsSeparate(t *testing.T) {
+	if err := initPaths(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	online := cachePath()
+	if err := initFixtures(true, false, filepath.Join("testdata", "fixtures")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		fixtures, fixturesDir = fixturesOff, ""
+		_ = useProfileDir(defaultProfileName)
+	})
+	if err := useProfileDir(defaultProfileName); err != nil {
+		t.Fatal(err)
+	}
+	if cachePath() == online {
+		t.Fatalf("offline cache at %s, same as the online one", cachePath())
+	}
+
+	if err := seedOfflineCache(); err != nil {
+		t.Fatal(err)
+	}
+	st, err := loadCachedState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode != "offline" || st.Shale.Avg != 4800 {
+		t.Errorf("offline cache holds mode %q, shale avg %d; want the replayed fixtures", st.Mode, st.Shale.Avg)
+	}
+	if hist, err := loadHistory(); err != nil || len(hist) != 1 {
+		t.Errorf("offline history has %d entries (err %v), want 1", len(hist), err)
+	}
+	if _, err := os.Stat(online); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("online cache touched: %v", err)
+	}
+}
+
+func TestDecodeCacheMigrations(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		check func(t *testing.T, st AppState)
+	}{
+		{
+			name: "v0 short armor list, missing avg",
+			doc:  `{"state": {"shale": {"high": 100, "low": 80}, "armors": [{"price": {"high": 10, "low": 6, "avg": 0}}]}}`,
+			check: func(t *testing.T, st AppState) {
+				if st.Shale.Avg != 90 {
+					t.Errorf("shale avg = %d, want 90", st.Shale.Avg)
+				}
+				if len(st.Armors) != 3 {
+					t.Fatalf("%d armors, want 3", len(st.Armors))
+				}
+				if a := st.Armors[0]; a.ItemID != armorID1 || a.Name == "" || a.Price.Avg != 8 {
+					t.Errorf("armor 0 = %+v, want defaults filled and avg 8", a)
+				}
+				if st.Armors[2].ItemID != armorID3 {
+					t.Errorf("armor 2 id = %d, want %d", st.Armors[2].ItemID, armorID3)
+				}
+				if st.Mode != "manual" {
+					t.Errorf("mode = %q, want manual", st.Mode)
+				}
+			},
+		},
+		{
+			name: "v1 passes through",
+			doc: `{"version": 1, "state": {"shale": {"high": 100, "low": 80, "avg": 0}, "mode": "api",
+				"armors": [{"name": "Oathplate Helmet", "item_id": 30750, "price": {"high": 10, "low": 6, "avg": 7}}]}}`,
+			check: func(t *testing.T, st AppState) {
+				if st.Shale != (PriceTriple{High: 100, Low: 80}) {
+					t.Errorf("shale = %+v, want it untouched", st.Shale)
+				}
+				if len(st.Armors) != 1 || st.Armors[0].Price.Avg != 7 {
+					t.Errorf("armors = %+v, want the one armor untouched", st.Armors)
+				}
+				if st.Mode != "api" {
+					t.Errorf("mode = %q, want api", st.Mode)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := decodeCache([]byte(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Version != cacheSchemaVersion {
+				t.Errorf("version = %d, want %d", c.Version, cacheSchemaVersion)
+			}
+			tt.check(t, c.State)
+		})
+	}
+}
+
+func TestCacheNewerSchemaLeftAlone(t *testing.T) {
+	if err := initPaths(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	newer := []byte(`{"version": 99, "state": {"future": true}}`)
+	if err := os.WriteFile(cachePath(), newer, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadCache(); !errors.Is(err, errNewerCache) {
+		t.Errorf("loadCache err = %v, want errNewerCache", err)
+	}
+	if err := saveCache(defaultState()); !errors.Is(err, errNewerCache) {
+		t.Errorf("saveCache err = %v, want errNewerCache", err)
+	}
+	if _, err := mergeIntoCache(defaultState()); !errors.Is(err, errNewerCache) {
+		t.Errorf("mergeIntoCache err = %v, want errNewerCache", err)
+	}
+
+	if b, _ := os.ReadFile(cachePath()); !bytes.Equal(b, newer) {
+		t.Errorf("cache rewritten to %s", b)
+	}
+	if m, _ := filepath.Glob(cachePath() + ".corrupt-*"); len(m) > 0 {
+		t.Errorf("newer cache backed up as corrupt: %v", m)
+	}
+}
+
+func TestCorruptCacheBackedUp(t *testing.T) {
+	for name, doc := range map[string]string{
+		"not an object": `[1, 2, 3]`,
+		"null":          `null`,
+		"missing state": `{"version": 0}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := initPaths(t.TempDir()); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(cachePath(), []byte(doc), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := loadCache(); !errors.Is(err, errCorruptCache) {
+				t.Fatalf("loadCache err = %v, want errCorruptCache", err)
+			}
+			m, _ := filepath.Glob(cachePath() + ".corrupt-*")
+			if len(m) != 1 {
+				t.Fatalf("backups = %v, want one", m)
+			}
+			if b, _ := os.ReadFile(m[0]); string(b) != doc {
+				t.Errorf("backup holds %s, want the original", b)
+			}
+
+			// the next save writes a fresh cache and leaves the backup be
+			if err := saveCache(defaultState()); err != nil {
+				t.Fatal(err)
+			}
+			if b, _ := os.ReadFile(m[0]); string(b) != doc {
+				t.Errorf("backup overwritten with %s", b)
+			}
+		})
+	}
+}
+
+// stubTransport answers wiki requests in tests instead of the network.
+type stubTransport func(*http.Request) (*http.Response, error)
+
+func (f stubTransport) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func stubResponse(status int, body string, h http.Header) *http.Response {
+	if h == nil {
+		h = http.Header{}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     h,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// stubWiki routes wiki requests to fn, with fast retries and an HTTP cache
+// of its own, and counts the requests made.
+func stubWiki(t *testing.T, fn func(*http.Request) *http.Response) *int {
+	t.Helper()
+	if err := initPaths(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	calls := new(int)
+	old, oldRetries, oldWait := wikiClient.Transport, fetchRetries, fetchRetryWait
+	wikiClient.Transport = stubTransport(func(r *http.Request) (*http.Response, error) {
+		*calls++
+		return fn(r), nil
+	})
+	fetchRetries, fetchRetryWait = 2, time.Millisecond
+	t.Cleanup(func() { wikiClient.Transport, fetchRetries, fetchRetryWait = old, oldRetries, oldWait })
+	return calls
+}
+
+func TestWikiGetRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []*http.Response
+		wantCalls int
+		wantErr   string // empty = success
+	}{
+		{
+			name:      "503 then 200",
+			responses: []*http.Response{stubResponse(503, "", nil), stubResponse(200, "ok", nil)},
+			wantCalls: 2,
+		},
+		{
+			name:      "Retry-After over the limit",
+			responses: []*http.Response{stubResponse(429, "", http.Header{"Retry-After": {"120"}})},
+			wantCalls: 1,
+			wantErr:   "Retry-After",
+		},
+		{
+			name:      "404 is not retried",
+			responses: []*http.Response{stubResponse(404, "", nil)},
+			wantCalls: 1,
+			wantErr:   "404",
+		},
+		{
+			name:      "gives up after the retries",
+			responses: []*http.Response{stubResponse(500, "", nil), stubResponse(500, "", nil), stubResponse(500, "", nil)},
+			wantCalls: 3,
+			wantErr:   "after 3 attempts",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := tt.responses
+			calls := stubWiki(t, func(*http.Request) *http.Response {
+				if len(next) == 0 {
+					t.Error("more requests than responses")
+					return stubResponse(400, "", nil)
+				}
+				r := next[0]
+				next = next[1:]
+				return r
+			})
+
+			res, err := wikiGet(context.Background(), "https://wiki.test/latest", time.Second)
+			if *calls != tt.wantCalls || res.Attempts != tt.wantCalls {
+				t.Errorf("%d requests, %d attempts; want %d", *calls, res.Attempts, tt.wantCalls)
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatal(err)
+			case tt.wantErr == "" && string(res.Body) != "ok":
+				t.Errorf("body = %q, want ok", res.Body)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("err = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchPartialKeepsPrev(t *testing.T) {
+	stubWiki(t, func(r *http.Request) *http.Response {
+		id := r.URL.Query().Get("id")
+		if id == fmt.Sprint(armorID2) {
+			return stubResponse(404, "", nil)
+		}
+		return stubResponse(200, `{"data": {"`+id+`": {"high": 10, "low": 6}}}`, nil)
+	})
+	prev := defaultState()
+	prev.Armors[1].Price = PriceTriple{High: 99, Low: 77, Avg: 88}
+
+	if _, err := FetchStateFromAPIContext(context.Background(), prev, nil); err == nil {
+		t.Error("fetch without -partial succeeded despite a failed item")
+	}
+
+	fetchPartial = true
+	t.Cleanup(func() { fetchPartial = false })
+	st, err := FetchStateFromAPIContext(context.Background(), prev, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(st.Stale, []string{"armor2"}) {
+		t.Errorf("Stale = %v, want [armor2]", st.Stale)
+	}
+	if st.Armors[1].Price != prev.Armors[1].Price {
+		t.Errorf("armor2 = %+v, want prev's %+v", st.Armors[1].Price, prev.Armors[1].Price)
+	}
+	if want := (PriceTriple{High: 10, Low: 6, Avg: 8}); st.Shale != want || st.Armors[0].Price != want {
+		t.Errorf("fetched items = %+v / %+v, want %+v", st.Shale, st.Armors[0].Price, want)
+	}
+
+	// nothing earlier to keep: the fetch fails after all
+	if _, err := FetchStateFromAPIContext(context.Background(), defaultState(), nil); err == nil {
+		t.Error("partial fetch without an earlier price succeeded")
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		header    string
+		age       string
+		wantStore bool
+		wantFresh time.Duration // 0 = revalidate every time
+	}{
+		{"", "", true, 0},
+		{"max-age=60", "", true, time.Minute},
+		{"public, max-age=60", "20", true, 40 * time.Second},
+		{"no-cache", "", true, 0},
+		{"no-cache, max-age=60", "", true, 0},
+		{"max-age=60, no-cache", "", true, 0},
+		{"no-store", "", false, 0},
+		{"max-age=0", "", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			h := http.Header{}
+			h.Set("Cache-Control", tt.header)
+			if tt.age != "" {
+				h.Set("Age", tt.age)
+			}
+			store, until := cacheControl(h, now)
+			var want time.Time
+			if tt.wantFresh > 0 {
+				want = now.Add(tt.wantFresh)
+			}
+			if store != tt.wantStore || !until.Equal(want) {
+				t.Errorf("got store %v, fresh until %v; want %v, %v", store, until, tt.wantStore, want)
+			}
+		})
+	}
+}
+
+func TestWikiGetRevalidates(t *testing.T) {
+	const url = "https://wiki.test/latest"
+	var gotINM string
+	calls := stubWiki(t, func(r *http.Request) *http.Response {
+		gotINM = r.Header.Get("If-None-Match")
+		return stubResponse(http.StatusNotModified, "", http.Header{
+			"Etag":          {`"v2"`},
+			"Last-Modified": {"Fri, 02 Jan 2026 03:04:05 GMT"},
+			"Cache-Control": {"max-age=60"},
+		})
+	})
+	stale := httpCacheEntry{URL: url, ETag: `"v1"`, StoredAt: time.Now().Add(-time.Hour), Body: []byte("cached")}
+	if err := saveHTTPCache(stale); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := wikiGet(context.Background(), url, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *calls != 1 || gotINM != `"v1"` {
+		t.Errorf("%d requests with If-None-Match %s; want 1 with \"v1\"", *calls, gotINM)
+	}
+	if string(res.Body) != "cached" {
+		t.Errorf("body = %q, want the cached one", res.Body)
+	}
+	e, ok := loadHTTPCache(url)
+	if !ok || e.ETag != `"v2"` || e.LastModified != "Fri, 02 Jan 2026 03:04:05 GMT" || !e.fresh(time.Now()) {
+		t.Errorf("entry after 304 = %+v, want the new validators and fresh", e)
+	}
+
+	// now fresh: answered without a request, unless revalidating
+	if _, err := wikiGet(context.Background(), url, time.Second); err != nil || *calls != 1 {
+		t.Errorf("fresh entry: %d requests, err %v; want none", *calls, err)
+	}
+	if _, err := wikiGet(withRevalidate(context.Background()), url, time.Second); err != nil || *calls != 2 {
+		t.Errorf("forced: %d requests, err %v; want a second one", *calls, err)
+	}
+}
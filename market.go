@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	mappingURL = "https://prices.runescape.wiki/api/v1/osrs/mapping"
+	volumesURL = "https://prices.runescape.wiki/api/v1/osrs/volumes"
+
+	// marketTTL is how long buy limits and volumes are reused. Volumes are a
+	// 24h figure, so there is no point asking more often.
+	marketTTL = time.Hour
+)
+
+// MarketInfo is GE trading data for one item.
+type MarketInfo struct {
+	ItemID    int
+	BuyLimit  int64 // per 4 hours, 0 = unknown
+	Volume    int64 // traded in the last 24 hours
+	FetchedAt time.Time
+}
+
+var market struct {
+	mu    sync.Mutex
+	items map[int]MarketInfo
+	at    time.Time
+}
+
+// LookupMarketInfo returns buy limit and daily volume for id, fetching both
+// wiki tables at most once per marketTTL.
+func LookupMarketInfo(id int) (MarketInfo, error) {
+	market.mu.Lock()
+	defer market.mu.Unlock()
+
+	if market.items == nil || time.Since(market.at) > marketTTL {
+		items, err := fetchMarketInfo()
+		if err != nil {
+			return MarketInfo{}, err
+		}
+		market.items, market.at = items, time.Now()
+	}
+	m, ok := market.items[id]
+	if !ok {
+		return MarketInfo{}, fmt.Errorf("no market data for item %d", id)
+	}
+	return m, nil
+}
+
+func fetchMarketInfo() (map[int]MarketInfo, error) {
+	var mapping []struct {
+		ID    int    `json:"id"`
+		Limit *int64 `json:"limit"`
+	}
+	if err := getWikiJSON(mappingURL, &mapping); err != nil {
+		return nil, fmt.Errorf("mapping: %w", err)
+	}
+	var volumes struct {
+		Data map[string]int64 `json:"data"`
+	}
+	if err := getWikiJSON(volumesURL, &volumes); err != nil {
+		return nil, fmt.Errorf("volumes: %w", err)
+	}
+
+	now := time.Now()
+	out := make(map[int]MarketInfo, len(mapping))
+	for _, it := range mapping {
+		m := MarketInfo{ItemID: it.ID, FetchedAt: now}
+		if it.Limit != nil {
+			m.BuyLimit = *it.Limit
+		}
+		out[it.ID] = m
+	}
+	for k, v := range volumes.Data {
+		id, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		m := out[id]
+		m.ItemID, m.Volume, m.FetchedAt = id, v, now
+		out[id] = m
+	}
+	return out, nil
+}
+
+func getWikiJSON(url string, v any) error {
+	res, err := wikiGet(context.Background(), url, 20*time.Second)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(res.Body, v)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+   METRICS (Prometheus text exposition, no client library)
+*/
+
+var fetchLatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type fetchStats struct {
+	mu        sync.Mutex
+	successes uint64
+	failures  uint64
+	buckets   []uint64 // cumulative counts per fetchLatencyBuckets entry
+	count     uint64
+	sum       float64
+	last      time.Time
+}
+
+var fetchMetrics = &fetchStats{buckets: make([]uint64, len(fetchLatencyBuckets))}
+
+func (m *fetchStats) observe(d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		m.failures++
+	} else {
+		m.successes++
+		m.last = time.Now()
+	}
+	secs := d.Seconds()
+	for i, le := range fetchLatencyBuckets {
+		if secs <= le {
+			m.buckets[i]++
+		}
+	}
+	m.count++
+	m.sum += secs
+}
+
+// instrumentedSource records count, outcome and latency of every fetch.
+type instrumentedSource struct {
+	src PriceSource
+}
+
+func (s instrumentedSource) Fetch(prev AppState) (AppState, error) {
+	start := time.Now()
+	st, err := s.src.Fetch(prev)
+	fetchMetrics.observe(time.Since(start), err)
+	return st, err
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	var rep *Report
+	if c, err := loadCache(); err == nil {
+		cur := ComputeReport(c.State)
+		rep = &cur
+	}
+	writeMetrics(w, rep)
+}
+
+func writeMetrics(w io.Writer, rep *Report) {
+	p := func(s string, args ...any) { fmt.Fprintf(w, s, args...) }
+	triple := func(name, item string, t PriceTriple) {
+		p("%s{item=%q,kind=\"high\"} %d\n", name, item, t.High)
+		p("%s{item=%q,kind=\"low\"} %d\n", name, item, t.Low)
+		p("%s{item=%q,kind=\"avg\"} %d\n", name, item, t.Avg)
+	}
+
+	if rep != nil {
+		p("# HELP oathplate_price_gp Latest known price per item.\n")
+		p("# TYPE oathplate_price_gp gauge\n")
+		triple("oathplate_price_gp", "shale", rep.Shale)
+		triple("oathplate_price_gp", "shard", rep.Shard)
+		for _, a := range rep.Armors {
+			triple("oathplate_price_gp", metricLabel(a.Name), a.Sale)
+		}
+
+		p("# HELP oathplate_ingredient_cost_gp Shale plus shards for one armor piece, per price tier.\n")
+		p("# TYPE oathplate_ingredient_cost_gp gauge\n")
+		p("oathplate_ingredient_cost_gp{tier=\"low\"} %d\n", rep.IngredientCost.Low)
+		p("oathplate_ingredient_cost_gp{tier=\"avg\"} %d\n", rep.IngredientCost.Avg)
+		p("oathplate_ingredient_cost_gp{tier=\"high\"} %d\n", rep.IngredientCost.High)
+
+		p("# HELP oathplate_profit_gp Profit after tax per armor and sale case.\n")
+		p("# TYPE oathplate_profit_gp gauge\n")
+		for _, a := range rep.Armors {
+			for _, c := range a.Cases {
+				p("oathplate_profit_gp{armor=%q,case=%q} %d\n", metricLabel(a.Name), c.SaleLabel, c.Profit)
+			}
+		}
+
+		if !rep.FetchedAt.IsZero() {
+			p("# HELP oathplate_cache_age_seconds Age of the cached prices.\n")
+			p("# TYPE oathplate_cache_age_seconds gauge\n")
+			p("oathplate_cache_age_seconds %g\n", rep.CacheAge.Seconds())
+		}
+	}
+
+	m := fetchMetrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p("# HELP oathplate_fetch_total Price fetches by result.\n")
+	p("# TYPE oathplate_fetch_total counter\n")
+	p("oathplate_fetch_total{result=\"success\"} %d\n", m.successes)
+	p("oathplate_fetch_total{result=\"failure\"} %d\n", m.failures)
+
+	p("# HELP oathplate_fetch_duration_seconds Time taken by a full price fetch.\n")
+	p("# TYPE oathplate_fetch_duration_seconds histogram\n")
+	for i, le := range fetchLatencyBuckets {
+		p("oathplate_fetch_duration_seconds_bucket{le=\"%g\"} %d\n", le, m.buckets[i])
+	}
+	p("oathplate_fetch_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.count)
+	p("oathplate_fetch_duration_seconds_sum %g\n", m.sum)
+	p("oathplate_fetch_duration_seconds_count %d\n", m.count)
+
+	if !m.last.IsZero() {
+		p("# HELP oathplate_last_fetch_success_timestamp_seconds Unix time of the last successful fetch.\n")
+		p("# TYPE oathplate_last_fetch_success_timestamp_seconds gauge\n")
+		p("oathplate_last_fetch_success_timestamp_seconds %d\n", m.last.Unix())
+	}
+}
+
+// metricLabel turns "Oathplate Chestplate" into "oathplate_chestplate".
+func metricLabel(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	appDirName     = "oathplate"
+	offlineDirName = "offline"
+)
+
+var (
+	// cacheBase is the root cache directory, set once by initPaths.
+	cacheBase = "."
+	// cacheDir holds the active profile's price cache and history: cacheBase
+	// for the default profile, cacheBase/profiles/<name> otherwise. With
+	// -offline both move under cacheBase/offline.
+	cacheDir = "."
+)
+
+/*
+   PATHS + SAFE FILE WRITES
+*/
+
+// initPaths picks the cache directory: the override if given, otherwise
+// $XDG_CACHE_HOME/oathplate (or the platform equivalent). A cache left in
+// the working directory by older versions is copied over on first use.
+func initPaths(override string) error {
+	dir := override
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return err
+		}
+		dir = filepath.Join(base, appDirName)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	cacheBase, cacheDir = dir, dir
+
+	for name, dst := range map[string]string{cacheFile: cachePath(), historyFile: historyPath()} {
+		if _, err := os.Stat(dst); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if b, err := os.ReadFile(name); err == nil {
+			if err := writeFileAtomic(dst, b, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// useProfileDir points cacheDir at the named profile's directory. Replayed
+// prices get a tree of their own so they never pass for fetched ones.
+func useProfileDir(profile string) error {
+	dir := cacheBase
+	if fixtures == fixturesReplay {
+		dir = filepath.Join(cacheBase, offlineDirName)
+	}
+	if profile != defaultProfileName {
+		dir = filepath.Join(dir, "profiles", profile)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	cacheDir = dir
+	return nil
+}
+
+// configDir is where user-edited settings live, next to but separate from
+// the cache.
+func configDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, appDirName), nil
+}
+
+func cachePath() string   { return filepath.Join(cacheDir, cacheFile) }
+func historyPath() string { return filepath.Join(cacheDir, historyFile) }
+
+// writeFileAtomic writes to a temp file in the target directory and renames
+// it into place, so readers see either the old or the new file, never half.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// withFileLock runs fn while holding an advisory lock on path+".lock". The
+// lock lives in a sidecar because the data file itself is replaced by
+// rename. Readers take a shared lock, writers an exclusive one.
+func withFileLock(path string, exclusive bool, fn func() error) error {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lockFile(f, exclusive); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+	return fn()
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultProfileName = "default"
+	geTaxBps           = 200 // 2% Grand Exchange tax
+)
+
+// Profile holds the per-account settings: who is selling, how they are
+// taxed, what they craft and what they already own. Each profile has its
+// own cache directory.
+type Profile struct {
+	Name      string    `json:"name"`
+	TaxBps    int64     `json:"tax_bps"`           // tax in basis points of the sale price (200 = 2%)
+	TaxCap    int64     `json:"tax_cap,omitempty"` // max tax per item in gp, 0 = uncapped
+	SaleBasis string    `json:"sale_basis"`        // low | avg | high: case used for the profile's pick
+	Recipe    Recipe    `json:"recipe"`
+	Inventory Inventory `json:"inventory,omitzero"`
+}
+
+// Recipe is the ingredient count per armor piece and which pieces to
+// consider.
+type Recipe struct {
+	Shale  int64 `json:"shale"`
+	Shards int64 `json:"shards"`
+	Armors []int `json:"armors,omitempty"` // item IDs; empty = all
+}
+
+// Inventory is what the account already holds.
+type Inventory struct {
+	Shale  int64 `json:"shale,omitempty"`
+	Shards int64 `json:"shards,omitempty"`
+	Cash   int64 `json:"cash,omitempty"`
+}
+
+// activeProfile drives ComputeReport. Set from --profile or the TUI menu.
+var activeProfile = defaultProfile()
+
+func defaultProfile() Profile {
+	return Profile{
+		Name:      defaultProfileName,
+		TaxBps:    geTaxBps,
+		SaleBasis: "avg",
+		Recipe:    Recipe{Shale: shaleNeeded, Shards: shardsNeeded},
+	}
+}
+
+// selectProfile loads the named profile, makes it active and switches the
+// cache directory to it.
+func selectProfile(name string) error {
+	p, err := loadProfile(name)
+	if err != nil {
+		return err
+	}
+	if err := useProfileDir(p.Name); err != nil {
+		return err
+	}
+	activeProfile = p
+	return nil
+}
+
+/*
+   PROFILES (storage)
+*/
+
+func profileDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "profiles"), nil
+}
+
+func profilePath(name string) (string, error) {
+	if !scenarioNameRE.MatchString(name) {
+		return "", fmt.Errorf("invalid profile name %q", name)
+	}
+	dir, err := profileDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// loadProfile reads a profile. The default profile need not exist on disk;
+// missing fields fall back to the defaults.
+func loadProfile(name string) (Profile, error) {
+	path, err := profilePath(name)
+	if err != nil {
+		return Profile{}, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && name == defaultProfileName {
+		return defaultProfile(), nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return Profile{}, fmt.Errorf("no profile named %q (create it with: profile new %s)", name, name)
+	}
+	if err != nil {
+		return Profile{}, err
+	}
+
+	p := defaultProfile()
+	if err := json.Unmarshal(b, &p); err != nil {
+		return Profile{}, fmt.Errorf("profile %s: %w", path, err)
+	}
+	p.Name = name
+	if err := p.validate(); err != nil {
+		return Profile{}, fmt.Errorf("profile %s: %w", path, err)
+	}
+	return p, nil
+}
+
+func saveProfile(p Profile) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	path, err := profilePath(p.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b, 0o644)
+}
+
+// listProfiles returns profile names, always including the default.
+func listProfiles() ([]string, error) {
+	names := []string{defaultProfileName}
+	dir, err := profileDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return names, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if n, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() && n != defaultProfileName {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names[1:])
+	return names, nil
+}
+
+func (p Profile) validate() error {
+	switch p.SaleBasis {
+	case "low", "avg", "high":
+	default:
+		return fmt.Errorf("sale_basis %q (use low|avg|high)", p.SaleBasis)
+	}
+	if p.TaxBps < 0 || p.TaxBps > 10_000 {
+		return fmt.Errorf("tax_bps %d out of range 0..10000", p.TaxBps)
+	}
+	if p.Recipe.Shale < 0 || p.Recipe.Shards < 0 {
+		return errors.New("recipe quantities must not be negative")
+	}
+	for _, id := range p.Recipe.Armors {
+		switch id {
+		case armorID1, armorID2, armorID3:
+		default:
+			return fmt.Errorf("armor item id %d unknown (use %d, %d or %d)", id, armorID1, armorID2, armorID3)
+		}
+	}
+	return nil
+}
+
+// tax returns the tax on one sale at this profile's rate and cap.
+func (p Profile) tax(salePrice int64) int64 {
+	t := salePrice * p.TaxBps / 10_000
+	if p.TaxCap > 0 && t > p.TaxCap {
+		t = p.TaxCap
+	}
+	return t
+}
+
+// breakEven is the lowest sale price whose after-tax proceeds cover cost.
+func (p Profile) breakEven(cost int64) int64 {
+	if cost <= 0 {
+		return 0
+	}
+	if p.TaxBps >= 10_000 && p.TaxCap == 0 {
+		return math.MaxInt64 // everything goes to tax
+	}
+	s := cost + p.TaxCap
+	if p.TaxBps < 10_000 {
+		uncapped := (cost*10_000 + 10_000 - p.TaxBps - 1) / (10_000 - p.TaxBps)
+		if p.TaxCap == 0 || uncapped < s {
+			s = uncapped
+		}
+	}
+	// the tax rounds down, so settle the last few gp by hand
+	for s-p.tax(s) < cost {
+		s++
+	}
+	for s > 0 && s-1-p.tax(s-1) >= cost {
+		s--
+	}
+	return s
+}
+
+// wantsArmor reports whether the recipe includes the armor item.
+func (p Profile) wantsArmor(itemID int) bool {
+	if len(p.Recipe.Armors) == 0 {
+		return true
+	}
+	for _, id := range p.Recipe.Armors {
+		if id == itemID {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Profile) describe() string {
+	s := fmt.Sprintf("%s (tax %s", p.Name, formatBps(p.TaxBps))
+	if p.TaxCap > 0 {
+		s += ", cap " + comma(p.TaxCap)
+	}
+	return s + ", sale basis " + p.SaleBasis + ")"
+}
+
+// gpValue is a flag.Value that accepts parseGP shorthand (125k, 1.2m).
+type gpValue struct{ p *int64 }
+
+func (v gpValue) String() string {
+	if v.p == nil {
+		return "0"
+	}
+	return strconv.FormatInt(*v.p, 10)
+}
+
+func (v gpValue) Set(s string) error {
+	n, err := parseGP(s)
+	if err != nil {
+		return err
+	}
+	*v.p = n
+	return nil
+}
+
+func formatBps(bps int64) string {
+	return strconv.FormatFloat(float64(bps)/100, 'f', -1, 64) + "%"
+}
+
+/*
+   CLI
+*/
+
+func runProfile(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: profile list | show [name] | new <name> [flags]")
+	}
+	verb, args := args[0], args[1:]
+
+	switch verb {
+	case "list":
+		names, err := listProfiles()
+		if err != nil {
+			return err
+		}
+		for _, n := range names {
+			mark := " "
+			if n == activeProfile.Name {
+				mark = "*"
+			}
+			p, err := loadProfile(n)
+			if err != nil {
+				fmt.Printf("%s %-16s (unreadable: %v)\n", mark, n, err)
+				continue
+			}
+			fmt.Printf("%s %s\n", mark, p.describe())
+		}
+	case "show":
+		p := activeProfile
+		if len(args) > 0 {
+			var err error
+			if p, err = loadProfile(args[0]); err != nil {
+				return err
+			}
+		}
+		b, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case "new":
+		if len(args) == 0 {
+			return errors.New("usage: profile new <name> [flags]")
+		}
+		p := defaultProfile()
+		p.Name = args[0]
+
+		fs := flag.NewFlagSet("profile new", flag.ContinueOnError)
+		fs.Int64Var(&p.TaxBps, "tax-bps", p.TaxBps, "sale tax in basis points (200 = 2%, 0 for ironman/no GE)")
+		fs.Var(gpValue{&p.TaxCap}, "tax-cap", "maximum tax per item in gp, e.g. 5m (0 = uncapped)")
+		fs.StringVar(&p.SaleBasis, "basis", p.SaleBasis, "preferred sale basis: low|avg|high")
+		fs.Int64Var(&p.Recipe.Shale, "shale", p.Recipe.Shale, "shale per armor piece")
+		fs.Int64Var(&p.Recipe.Shards, "shards", p.Recipe.Shards, "shards per armor piece")
+		armors := fs.String("armors", "", "comma-separated armor item IDs to consider (default all)")
+		fs.Int64Var(&p.Inventory.Shale, "have-shale", 0, "shale already owned")
+		fs.Int64Var(&p.Inventory.Shards, "have-shards", 0, "shards already owned")
+		fs.Var(gpValue{&p.Inventory.Cash}, "cash", "gp available for buying ingredients, e.g. 250m")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *armors != "" {
+			for _, s := range strings.Split(*armors, ",") {
+				id, err := strconv.Atoi(strings.TrimSpace(s))
+				if err != nil {
+					return fmt.Errorf("armors: %w", err)
+				}
+				p.Recipe.Armors = append(p.Recipe.Armors, id)
+			}
+		}
+		if path, _ := profilePath(p.Name); path != "" {
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("profile %q already exists (%s)", p.Name, path)
+			}
+		}
+		if err := saveProfile(p); err != nil {
+			return err
+		}
+		path, _ := profilePath(p.Name)
+		fmt.Printf("Created profile %s at %s\n", p.describe(), path)
+	default:
+		return fmt.Errorf("unknown profile command %q", verb)
+	}
+	return nil
+}
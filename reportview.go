@@ -0,0 +1,275 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+/*
+   TUI REPORT VIEW (summary, armor table, detail pane)
+*/
+
+// renderSummaryTagged is the part of the report above the armor table, with
+// tview colour tags.
+func renderSummaryTagged(r Report) string {
+	var b strings.Builder
+	w := func(s string, args ...any) { fmt.Fprintf(&b, s, args...) }
+
+	px := func(target, comp string, v int64) string {
+		if r.IsManual(target, comp) {
+			return fmt.Sprintf("[yellow]%13s*[-]", comma(v))
+		}
+		return fmt.Sprintf("%13s ", comma(v))
+	}
+	row := func(label, target string, t PriceTriple) {
+		stale := ""
+		if r.IsStale(target) {
+			stale = " [red]stale[-]"
+		}
+		w("  %-18s %s / %s / %s gp%s\n", label, px(target, "high", t.High), px(target, "low", t.Low), px(target, "avg", t.Avg), stale)
+	}
+
+	w("[gray]PRICES (high / low / avg), [yellow]*[gray] = manual[-]\n")
+	row("Infernal Shale", "shale", r.Shale)
+	row("Oathplate Shards", "shard", r.Shard)
+	w("[gray]INGREDIENTS[-] %s shale + %s shards: low %s | avg %s | high %s gp\n",
+		comma(r.Recipe.Shale), comma(r.Recipe.Shards),
+		comma(r.IngredientCost.Low), comma(r.IngredientCost.Avg), comma(r.IngredientCost.High))
+	if inv := r.Inventory; inv.Have != (Inventory{}) {
+		w("[gray]STOCK[-] craftable %d | next piece needs %s gp | affordable %d\n",
+			inv.FromStock, comma(inv.NextCraftCost), inv.Affordable)
+	}
+	basis := cmp.Or(r.SaleBasis, "avg")
+	if r.BestForBasis.Name != "" {
+		w("[gray]BEST @ %s[-] [::b]%s[::-] (%s gp)\n", basis, r.BestForBasis.Name, colourPad(profitForLabel(r.BestForBasis, basis), 0))
+	}
+	return b.String()
+}
+
+// reportColumns are the armor table columns after the name.
+var reportColumns = []string{"Sale low", "Sale avg", "Sale high", "Profit low", "Profit avg", "Profit high", "ROI"}
+
+// fillReportTable lays out one row per armor (in the report's sort order)
+// and returns the armors in row order, so row i+1 is armors[i]. The best
+// pick's cell at the sale basis is highlighted. Colours come from
+// activeTheme.
+func fillReportTable(t *tview.Table, r Report) []ArmorReport {
+	armors := append([]ArmorReport(nil), r.Armors...)
+	basis := cmp.Or(r.SaleBasis, "avg")
+	sortArmors(armors, cmp.Or(r.SortBy, sortKeys[0]), basis)
+
+	th := activeTheme
+	t.Clear()
+	header := func(col int, text string) {
+		t.SetCell(0, col, tview.NewTableCell(text).
+			SetTextColor(th.Muted).
+			SetAlign(tview.AlignRight).
+			SetSelectable(false))
+	}
+	header(0, "Armor")
+	t.GetCell(0, 0).SetAlign(tview.AlignLeft)
+	for i, h := range reportColumns {
+		if h == "ROI" {
+			h = "ROI @" + basis
+		}
+		header(i+1, h)
+	}
+
+	for i, a := range armors {
+		row := i + 1
+		name := a.Name
+		if a.Name == r.BestForBasis.Name {
+			name = "★ " + name
+		}
+		nameCell := tview.NewTableCell(name).SetTextColor(th.Text).SetExpansion(1)
+		if r.IsStale(a.Slot) {
+			nameCell.SetText(name + " (stale)").SetTextColor(th.Loss)
+		}
+		t.SetCell(row, 0, nameCell)
+
+		col := 1
+		for _, c := range a.Cases {
+			cell := tview.NewTableCell(comma(c.SalePrice)).SetTextColor(th.Text).SetAlign(tview.AlignRight)
+			if r.IsManual(a.Slot, c.SaleLabel) {
+				cell.SetText(comma(c.SalePrice) + "*").SetTextColor(th.Manual)
+			}
+			t.SetCell(row, col, cell)
+			col++
+		}
+		for _, c := range a.Cases {
+			cell := tview.NewTableCell(signedComma(c.Profit)).SetTextColor(th.Text).SetAlign(tview.AlignRight)
+			switch {
+			case c.Profit > 0:
+				cell.SetTextColor(th.Profit)
+			case c.Profit < 0:
+				cell.SetTextColor(th.Loss)
+			}
+			if a.Name == r.BestForBasis.Name && c.SaleLabel == basis {
+				cell.SetAttributes(tcell.AttrBold).SetBackgroundColor(th.Best).SetTextColor(th.Text)
+			}
+			t.SetCell(row, col, cell)
+			col++
+		}
+		t.SetCell(row, col, tview.NewTableCell(fmt.Sprintf("%.1f%%", roiForLabel(a, basis)*100)).SetTextColor(th.Text).SetAlign(tview.AlignRight))
+	}
+	return armors
+}
+
+// renderArmorDetail is the per-case breakdown shown under the table.
+func renderArmorDetail(a ArmorReport, r Report) string {
+	var b strings.Builder
+	w := func(s string, args ...any) { fmt.Fprintf(&b, s, args...) }
+
+	w("[::b]%s[::-] [gray](item %d, %s)[-]\n", a.Name, a.ItemID, a.Slot)
+	w("[gray]%-5s %14s %12s %14s %16s %14s %8s[-]\n", "case", "sale", "tax", "net", "ingredients", "profit", "roi")
+	for _, c := range a.Cases {
+		w("%-5s %14s %12s %14s %16s %14s %7.1f%%\n",
+			c.SaleLabel,
+			comma(c.SalePrice),
+			comma(c.TaxPaid),
+			comma(c.NetAfterTax),
+			comma(c.NetAfterTax-c.Profit),
+			colourPad(c.Profit, 14),
+			c.roi()*100,
+		)
+	}
+	w("[gray]Tax %s of the sale price; ingredients use the same tier as the sale (low with low, ...).[-]\n", formatBps(r.TaxBps))
+	return b.String()
+}
+
+// colourPad right-aligns a signed amount, green above zero and red below;
+// colour tags don't count toward the width.
+func colourPad(v int64, width int) string {
+	s := signedComma(v)
+	pad := strings.Repeat(" ", max(width-len(s), 0))
+	switch {
+	case v > 0:
+		return pad + "[green]" + s + "[-]"
+	case v < 0:
+		return pad + "[red]" + s + "[-]"
+	default:
+		return pad + s
+	}
+}
+
+/*
+   TUI ARMOR PAGE (drill-down)
+*/
+
+// historySamples is how many history entries the armor page sparkline covers.
+const historySamples = 48
+
+// armorHistory pulls an armor's avg price out of the fetch history, oldest
+// first, skipping entries without it or where it was only kept as stale.
+func armorHistory(hist []AppState, itemID int) []int64 {
+	var out []int64
+	for _, s := range hist {
+		for i, a := range s.Armors {
+			if slices.Contains(s.Stale, fmt.Sprintf("armor%d", i+1)) {
+				continue // not fetched that time
+			}
+			if a.ItemID == itemID && a.Price.Avg > 0 {
+				out = append(out, a.Price.Avg)
+			}
+		}
+	}
+	if len(out) > historySamples {
+		out = out[len(out)-historySamples:]
+	}
+	return out
+}
+
+// sparkline draws values as one row of block characters scaled between
+// their min and max.
+func sparkline(values []int64) string {
+	const bars = "▁▂▃▄▅▆▇█"
+	blocks := []rune(bars)
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := slices.Min(values), slices.Max(values)
+	var b strings.Builder
+	for _, v := range values {
+		i := len(blocks) - 1
+		if hi > lo {
+			i = int((v - lo) * int64(len(blocks)-1) / (hi - lo))
+		}
+		b.WriteRune(blocks[i])
+	}
+	return b.String()
+}
+
+// renderArmorPage is the full drill-down for one armor: ingredient costs per
+// tier, tax, break-even, price history and market data. market is nil while
+// it is still loading; marketErr explains a failed lookup.
+func renderArmorPage(a ArmorReport, r Report, p Profile, hist []AppState, market *MarketInfo, marketErr error) string {
+	var b strings.Builder
+	w := func(s string, args ...any) { fmt.Fprintf(&b, s, args...) }
+	tiers := func(label string, f func(tier string) string) {
+		w("  %-30s %16s %16s %16s\n", label, f("low"), f("avg"), f("high"))
+	}
+	pick := func(t PriceTriple, tier string) int64 {
+		return map[string]int64{"low": t.Low, "avg": t.Avg, "high": t.High}[tier]
+	}
+	cases := map[string]ProfitCase{}
+	for _, c := range a.Cases {
+		cases[c.SaleLabel] = c
+	}
+
+	w("[::b]%s[::-] [gray](item %d, %s)[-]\n\n", a.Name, a.ItemID, a.Slot)
+
+	w("[gray]%-32s %16s %16s %16s[-]\n", "INGREDIENTS (per piece)", "low", "avg", "high")
+	tiers(fmt.Sprintf("%s × Infernal Shale", comma(r.Recipe.Shale)), func(t string) string {
+		return comma(r.Recipe.Shale * pick(r.Shale, t))
+	})
+	tiers(fmt.Sprintf("%s × Oathplate Shards", comma(r.Recipe.Shards)), func(t string) string {
+		return comma(r.Recipe.Shards * pick(r.Shard, t))
+	})
+	tiers("Total", func(t string) string { return comma(pick(r.IngredientCost, t)) })
+	tiers("  unit prices", func(t string) string {
+		return formatGPShort(pick(r.Shale, t)) + " / " + formatGPShort(pick(r.Shard, t))
+	})
+	b.WriteString("\n")
+
+	taxNote := formatBps(p.TaxBps) + " of sale"
+	if p.TaxCap > 0 {
+		taxNote += ", capped at " + comma(p.TaxCap)
+	}
+	w("[gray]%-32s %16s %16s %16s[-]\n", "SALE", "low", "avg", "high")
+	tiers("Sale price", func(t string) string { return comma(cases[t].SalePrice) })
+	tiers("Tax ("+taxNote+")", func(t string) string { return comma(cases[t].TaxPaid) })
+	tiers("Net after tax", func(t string) string { return comma(cases[t].NetAfterTax) })
+	tiers("Profit", func(t string) string { return colourPad(cases[t].Profit, 16) })
+	tiers("ROI", func(t string) string { return fmt.Sprintf("%.1f%%", cases[t].roi()*100) })
+	tiers("Break-even sale price", func(t string) string { return comma(p.breakEven(pick(r.IngredientCost, t))) })
+	b.WriteString("\n")
+
+	w("[gray]PRICE HISTORY (avg, last %d fetches)[-]\n", historySamples)
+	if vals := armorHistory(hist, a.ItemID); len(vals) > 1 {
+		w("  %s  low %s, high %s, now %s\n", sparkline(vals),
+			formatGPShort(slices.Min(vals)), formatGPShort(slices.Max(vals)), formatGPShort(vals[len(vals)-1]))
+	} else {
+		w("  Not enough history yet (run watch or fetch a few times).\n")
+	}
+	b.WriteString("\n")
+
+	w("[gray]MARKET[-]\n")
+	switch {
+	case marketErr != nil:
+		w("  [red]unavailable[-]: %v\n", marketErr)
+	case market == nil:
+		w("  loading…\n")
+	default:
+		limit := "unknown"
+		if market.BuyLimit > 0 {
+			limit = comma(market.BuyLimit) + " per 4h"
+		}
+		w("  Buy limit: %s | Volume (24h): %s\n", limit, comma(market.Volume))
+	}
+	return b.String()
+}
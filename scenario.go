@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// currentScenario is the pseudo-scenario name for the live cache.
+const currentScenario = "current"
+
+var scenarioNameRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// Scenario is a named, frozen AppState saved next to the cache for what-if
+// comparisons.
+type Scenario struct {
+	Name        string    `json:"name"`
+	SavedAt     time.Time `json:"saved_at"`
+	Adjustments []string  `json:"adjustments,omitempty"`
+	State       AppState  `json:"state"`
+}
+
+type ScenarioReport struct {
+	Name   string
+	Report Report
+}
+
+/*
+   SCENARIOS (storage)
+*/
+
+func scenarioDir() string { return filepath.Join(cacheDir, "scenarios") }
+
+func scenarioPath(name string) (string, error) {
+	if !scenarioNameRE.MatchString(name) || name == currentScenario {
+		return "", fmt.Errorf("invalid scenario name %q (letters, digits, . _ + -; not %q)", name, currentScenario)
+	}
+	return filepath.Join(scenarioDir(), name+".json"), nil
+}
+
+// NewScenario snapshots state with overrides baked in, then applies the
+// adjustments (see applyAdjustment).
+func NewScenario(name string, state AppState, adjustments []string) (Scenario, error) {
+	snap := effectiveState(state, time.Now())
+	snap.Overrides = nil
+	for _, adj := range adjustments {
+		if err := applyAdjustment(&snap, adj); err != nil {
+			return Scenario{}, err
+		}
+	}
+	if len(adjustments) > 0 {
+		snap.Mode = "scenario"
+	}
+	return Scenario{Name: name, SavedAt: time.Now(), Adjustments: adjustments, State: snap}, nil
+}
+
+func saveScenario(sc Scenario) error {
+	path, err := scenarioPath(sc.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(scenarioDir(), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(sc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b, 0o644)
+}
+
+func loadScenario(name string) (Scenario, error) {
+	path, err := scenarioPath(name)
+	if err != nil {
+		return Scenario{}, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Scenario{}, fmt.Errorf("no scenario named %q", name)
+	}
+	if err != nil {
+		return Scenario{}, err
+	}
+	var sc Scenario
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return Scenario{}, fmt.Errorf("scenario %q: %w", name, err)
+	}
+	return sc, nil
+}
+
+func deleteScenario(name string) error {
+	path, err := scenarioPath(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// listScenarios returns saved scenario names, sorted.
+func listScenarios() ([]string, error) {
+	entries, err := os.ReadDir(scenarioDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// scenarioReports computes a report per name; "current" is the given live
+// state.
+func scenarioReports(current AppState, names []string) ([]ScenarioReport, error) {
+	out := make([]ScenarioReport, 0, len(names))
+	for _, n := range names {
+		if n == currentScenario {
+			out = append(out, ScenarioReport{Name: n, Report: ComputeReport(current)})
+			continue
+		}
+		sc, err := loadScenario(n)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ScenarioReport{Name: n, Report: ComputeReport(sc.State)})
+	}
+	return out, nil
+}
+
+/*
+   ADJUSTMENTS
+*/
+
+// applyAdjustment changes prices in a scenario snapshot. Forms:
+//
+//	shard-10%        all of shard's high/low/avg down 10%
+//	legs.avg+5%      one component up 5%
+//	shale.low=29k    absolute value
+func applyAdjustment(state *AppState, adj string) error {
+	i := strings.IndexAny(adj, "+-=")
+	if i <= 0 {
+		return fmt.Errorf("bad adjustment %q (try shard-10%%, legs+5%%, shale.low=29k)", adj)
+	}
+	field, op, arg := adj[:i], adj[i], adj[i+1:]
+
+	target, components, err := parseField(state, field)
+	if err != nil {
+		return fmt.Errorf("adjustment %q: %w", adj, err)
+	}
+	t, _ := fieldTriple(state, target)
+
+	var apply func(int64) int64
+	if op == '=' {
+		v, err := parseGP(arg)
+		if err != nil {
+			return fmt.Errorf("adjustment %q: %w", adj, err)
+		}
+		apply = func(int64) int64 { return v }
+	} else {
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+		if err != nil || !strings.HasSuffix(arg, "%") {
+			return fmt.Errorf("adjustment %q: want a percentage like 10%%", adj)
+		}
+		if op == '-' {
+			pct = -pct
+		}
+		apply = func(v int64) int64 { return int64(math.Round(float64(v) * (1 + pct/100))) }
+	}
+
+	for _, c := range components {
+		switch c {
+		case "high":
+			t.High = apply(t.High)
+		case "low":
+			t.Low = apply(t.Low)
+		case "avg":
+			t.Avg = apply(t.Avg)
+		}
+	}
+	return nil
+}
+
+/*
+   RENDER (comparison)
+*/
+
+// RenderComparisonString lays scenarios side by side: avg profit per armor
+// and the ingredient cost, each with its delta against the first scenario.
+func RenderComparisonString(rs []ScenarioReport) string {
+	var b strings.Builder
+	w := func(s string, args ...any) { b.WriteString(fmt.Sprintf(s, args...)) }
+	if len(rs) == 0 {
+		return "No scenarios to compare.\n"
+	}
+
+	const labelW, colW = 22, 26
+	cell := func(v, base int64, first bool) string {
+		if first {
+			return comma(v)
+		}
+		return fmt.Sprintf("%s (%s)", comma(v), signedComma(v-base))
+	}
+
+	b.WriteString(strings.Repeat("=", labelW+colW*len(rs)) + "\n")
+	w("SCENARIO COMPARISON (profit @ avg; deltas vs %s)\n", rs[0].Name)
+	b.WriteString(strings.Repeat("-", labelW+colW*len(rs)) + "\n")
+
+	w("%-*s", labelW, "")
+	for _, r := range rs {
+		w("%*s", colW, r.Name)
+	}
+	b.WriteString("\n")
+
+	w("%-*s", labelW, "Ingredient cost avg")
+	for i, r := range rs {
+		w("%*s", colW, cell(r.Report.IngredientCost.Avg, rs[0].Report.IngredientCost.Avg, i == 0))
+	}
+	b.WriteString("\n")
+
+	for ai, a := range rs[0].Report.Armors {
+		w("%-*s", labelW, a.Name)
+		base := profitForLabel(a, "avg")
+		for i, r := range rs {
+			if ai >= len(r.Report.Armors) {
+				w("%*s", colW, "-")
+				continue
+			}
+			w("%*s", colW, cell(profitForLabel(r.Report.Armors[ai], "avg"), base, i == 0))
+		}
+		b.WriteString("\n")
+	}
+
+	w("%-*s", labelW, "Best by avg profit")
+	for _, r := range rs {
+		w("%*s", colW, r.Report.BestByAvgProfit.Name)
+	}
+	b.WriteString("\n")
+	b.WriteString(strings.Repeat("=", labelW+colW*len(rs)) + "\n")
+	return b.String()
+}
+
+/*
+   CLI
+*/
+
+func runScenario(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: scenario save <name> [adjustments...] | list | show <name> | delete <name> | compare [names...]")
+	}
+	verb, args := args[0], args[1:]
+
+	current, err := loadCachedState()
+	if err != nil {
+		return err
+	}
+
+	switch verb {
+	case "save":
+		if len(args) == 0 {
+			return errors.New("usage: scenario save <name> [shard-10% legs+5% shale.low=29k ...]")
+		}
+		sc, err := NewScenario(args[0], current, args[1:])
+		if err != nil {
+			return err
+		}
+		if err := saveScenario(sc); err != nil {
+			return err
+		}
+		fmt.Printf("Saved scenario %q.\n", sc.Name)
+	case "list":
+		names, err := listScenarios()
+		if err != nil {
+			return err
+		}
+		for _, n := range names {
+			sc, err := loadScenario(n)
+			if err != nil {
+				fmt.Printf("  %-20s (unreadable: %v)\n", n, err)
+				continue
+			}
+			fmt.Printf("  %-20s saved %s  %s\n", n, sc.SavedAt.Local().Format("2006-01-02 15:04"), strings.Join(sc.Adjustments, " "))
+		}
+		if len(names) == 0 {
+			fmt.Println("No saved scenarios.")
+		}
+	case "show":
+		if len(args) != 1 {
+			return errors.New("usage: scenario show <name>")
+		}
+		sc, err := loadScenario(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Print(RenderReportString(ComputeReport(sc.State)))
+	case "delete":
+		if len(args) != 1 {
+			return errors.New("usage: scenario delete <name>")
+		}
+		return deleteScenario(args[0])
+	case "compare":
+		names := args
+		if len(names) == 0 {
+			saved, err := listScenarios()
+			if err != nil {
+				return err
+			}
+			names = append([]string{currentScenario}, saved...)
+		}
+		rs, err := scenarioReports(current, names)
+		if err != nil {
+			return err
+		}
+		fmt.Print(RenderComparisonString(rs))
+	default:
+		return fmt.Errorf("unknown scenario command %q", verb)
+	}
+	return nil
+}
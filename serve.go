@@ -0,0 +1,200 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//go:embed web/index.html
+var indexHTML []byte
+
+/*
+   SERVE (REST API over the cache)
+*/
+
+type server struct {
+	src PriceSource
+
+	mu        sync.Mutex
+	lastFetch time.Time    // last successful refresh
+	inflight  *refreshCall // refresh in progress, shared by concurrent ?refresh=1
+}
+
+// refreshCall is one refresh; done is closed once st and err are set.
+type refreshCall struct {
+	done chan struct{}
+	st   AppState
+	err  error
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "listen address")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s := &server{src: instrumentedSource{wikiSource{}}}
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		// a ?refresh=1 waits for the fetch, retries included
+		WriteTimeout: 5 * time.Minute,
+		IdleTimeout:  2 * time.Minute,
+	}
+	log.Printf("serve: listening on http://%s", *addr)
+	return srv.ListenAndServe()
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	mux.HandleFunc("GET /report", s.handleReport)
+	mux.HandleFunc("GET /prices", s.handlePrices)
+	mux.HandleFunc("POST /scenario", s.handleScenario)
+	mux.HandleFunc("GET /history", s.handleHistory)
+	mux.HandleFunc("GET /metrics", handleMetrics)
+	return mux
+}
+
+func (s *server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(indexHTML)
+}
+
+// currentState returns the cached state, refreshing it from the source first
+// when ?refresh=1 is given. Successful refreshes share the watch minimum
+// interval so a busy client can't turn the server into an API hammer; the
+// X-Refresh header says whether the refresh ran or was throttled.
+// Concurrent refreshes share one fetch, and plain reads never wait for it.
+func (s *server) currentState(w http.ResponseWriter, r *http.Request) (AppState, error) {
+	if r.URL.Query().Get("refresh") != "1" {
+		return loadCachedState()
+	}
+
+	s.mu.Lock()
+	if c := s.inflight; c != nil {
+		s.mu.Unlock()
+		<-c.done
+		w.Header().Set("X-Refresh", "fetched")
+		return c.st, c.err
+	}
+	if wait := minPollInterval - time.Since(s.lastFetch); wait > 0 {
+		s.mu.Unlock()
+		w.Header().Set("X-Refresh", "throttled; next refresh in "+roundDuration(wait))
+		return loadCachedState()
+	}
+	c := &refreshCall{done: make(chan struct{})}
+	s.inflight = c
+	s.mu.Unlock()
+
+	c.st, c.err = s.refresh()
+
+	s.mu.Lock()
+	s.inflight = nil
+	if c.err == nil {
+		s.lastFetch = time.Now()
+	}
+	s.mu.Unlock()
+	close(c.done)
+
+	w.Header().Set("X-Refresh", "fetched")
+	return c.st, c.err
+}
+
+// refresh fetches, records history and updates the cache, keeping its
+// overrides.
+func (s *server) refresh() (AppState, error) {
+	prev, _ := loadCachedState()
+	st, err := s.src.Fetch(prev)
+	if err != nil {
+		return AppState{}, fmt.Errorf("fetch: %w", err)
+	}
+	if err := appendHistory(st); err != nil {
+		log.Printf("serve: append history: %v", err)
+	}
+	st, err = mergeIntoCache(st)
+	if err != nil {
+		log.Printf("serve: save cache: %v", err)
+	}
+	return st, nil
+}
+
+func (s *server) handleReport(w http.ResponseWriter, r *http.Request) {
+	st, err := s.currentState(w, r)
+	if err != nil {
+		httpError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, ComputeReport(st))
+}
+
+func (s *server) handlePrices(w http.ResponseWriter, r *http.Request) {
+	st, err := s.currentState(w, r)
+	if err != nil {
+		httpError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, st)
+}
+
+// handleScenario computes a report for the posted state. Nothing is cached.
+func (s *server) handleScenario(w http.ResponseWriter, r *http.Request) {
+	var st AppState
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&st); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("decode state: %w", err))
+		return
+	}
+	if st.Mode == "" {
+		st.Mode = "scenario"
+	}
+	writeJSON(w, ComputeReport(st))
+}
+
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	hist, err := loadHistory()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("bad limit %q", v))
+			return
+		}
+		if n < len(hist) {
+			hist = hist[len(hist)-n:]
+		}
+	}
+	if hist == nil {
+		hist = []AppState{}
+	}
+	writeJSON(w, hist)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("serve: encode response: %v", err)
+	}
+}
+
+func httpError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
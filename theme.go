@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const themeFile = "theme.json"
+
+// Theme is the TUI colour set. Text in the TUI uses the plain tview tags
+// [red] [green] [yellow] [gray] for error/loss, ok/profit, manual and muted
+// text; Tags maps them onto the theme.
+type Theme struct {
+	Name       string
+	Background tcell.Color
+	Text       tcell.Color
+	Muted      tcell.Color // labels, headers, secondary text
+	Border     tcell.Color // plain panels
+	Accent     tcell.Color // report and page borders
+	Selected   tcell.Color // selection background; selected text uses Background
+	Manual     tcell.Color // manual overrides, command prompt
+	Profit     tcell.Color // also success messages
+	Loss       tcell.Color // also errors
+	Best       tcell.Color // background of the best pick
+	Warning    tcell.Color // background of invalid inputs and the stale banner
+}
+
+var builtinThemes = map[string]Theme{
+	"dark": {
+		Background: tcell.ColorBlack,
+		Text:       tcell.ColorWhite,
+		Muted:      tcell.ColorGray,
+		Border:     tcell.ColorGray,
+		Accent:     tcell.ColorRed,
+		Selected:   tcell.ColorWhite,
+		Manual:     tcell.ColorYellow,
+		Profit:     tcell.ColorGreen,
+		Loss:       tcell.ColorRed,
+		Best:       tcell.ColorDarkGreen,
+		Warning:    tcell.ColorDarkRed,
+	},
+	"light": {
+		Background: tcell.ColorWhite,
+		Text:       tcell.ColorBlack,
+		Muted:      tcell.ColorDimGray,
+		Border:     tcell.ColorDarkGray,
+		Accent:     tcell.ColorDarkRed,
+		Selected:   tcell.ColorNavy,
+		Manual:     tcell.ColorDarkOrange,
+		Profit:     tcell.ColorDarkGreen,
+		Loss:       tcell.ColorRed,
+		Best:       tcell.ColorPaleGreen,
+		Warning:    tcell.ColorLightPink,
+	},
+	"high-contrast": {
+		Background: tcell.ColorBlack,
+		Text:       tcell.ColorWhite,
+		Muted:      tcell.ColorWhite,
+		Border:     tcell.ColorWhite,
+		Accent:     tcell.ColorYellow,
+		Selected:   tcell.ColorYellow,
+		Manual:     tcell.ColorAqua,
+		Profit:     tcell.ColorLime,
+		Loss:       tcell.ColorFuchsia,
+		Best:       tcell.ColorBlue,
+		Warning:    tcell.ColorMaroon,
+	},
+	// Okabe-Ito blue/orange instead of green/red for profit and loss
+	"colour-blind": {
+		Background: tcell.ColorBlack,
+		Text:       tcell.ColorWhite,
+		Muted:      tcell.ColorGray,
+		Border:     tcell.ColorGray,
+		Accent:     tcell.NewHexColor(0x0072b2),
+		Selected:   tcell.ColorWhite,
+		Manual:     tcell.NewHexColor(0xf0e442),
+		Profit:     tcell.NewHexColor(0x56b4e9),
+		Loss:       tcell.NewHexColor(0xe69f00),
+		Best:       tcell.NewHexColor(0x0072b2),
+		Warning:    tcell.NewHexColor(0xd55e00),
+	},
+}
+
+// activeTheme drives the TUI colours. Set from theme.json or at runtime.
+var activeTheme = themeNamed("dark")
+
+func themeNamed(name string) Theme {
+	t := builtinThemes[name]
+	t.Name = name
+	return t
+}
+
+// themeNames lists the built-in themes, sorted.
+func themeNames() []string {
+	names := make([]string, 0, len(builtinThemes))
+	for n := range builtinThemes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// roles maps theme.json keys to the colours they set.
+func (t *Theme) roles() map[string]*tcell.Color {
+	return map[string]*tcell.Color{
+		"background": &t.Background,
+		"text":       &t.Text,
+		"muted":      &t.Muted,
+		"border":     &t.Border,
+		"accent":     &t.Accent,
+		"selected":   &t.Selected,
+		"manual":     &t.Manual,
+		"profit":     &t.Profit,
+		"loss":       &t.Loss,
+		"best":       &t.Best,
+		"warning":    &t.Warning,
+	}
+}
+
+func themePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, themeFile), nil
+}
+
+// loadTheme reads theme.json: a built-in to start from plus any colours to
+// change, as names or #rrggbb:
+//
+//	{"theme": "dark", "profit": "#56b4e9", "loss": "orange"}
+//
+// A missing file gives the dark theme; on error the dark theme is returned
+// along with it.
+func loadTheme() (Theme, error) {
+	def := themeNamed("dark")
+	path, err := themePath()
+	if err != nil {
+		return def, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return def, nil
+	}
+	if err != nil {
+		return def, err
+	}
+
+	var raw map[string]string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return def, fmt.Errorf("theme %s: %w", path, err)
+	}
+	name := raw["theme"]
+	if name == "" {
+		name = "dark"
+	}
+	if _, ok := builtinThemes[name]; !ok {
+		return def, fmt.Errorf("theme %s: unknown theme %q (use %s)", path, name, strings.Join(themeNames(), "|"))
+	}
+	t := themeNamed(name)
+	roles := t.roles()
+	for k, v := range raw {
+		if k == "theme" {
+			continue
+		}
+		c, ok := roles[k]
+		if !ok {
+			return def, fmt.Errorf("theme %s: unknown colour %q", path, k)
+		}
+		col := tcell.GetColor(v)
+		if col == tcell.ColorDefault {
+			return def, fmt.Errorf("theme %s: %s: bad colour %q", path, k, v)
+		}
+		*c = col
+	}
+	if len(raw) > 1 {
+		t.Name += " (custom)"
+	}
+	return t, nil
+}
+
+// Tags rewrites the conventional colour tags in s to the theme's colours.
+func (t Theme) Tags(s string) string {
+	tag := func(c tcell.Color) string { return fmt.Sprintf("[#%06x]", c.Hex()) }
+	return strings.NewReplacer(
+		"[red]", tag(t.Loss),
+		"[green]", tag(t.Profit),
+		"[yellow]", tag(t.Manual),
+		"[gray]", tag(t.Muted),
+	).Replace(s)
+}
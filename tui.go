@@ -1,7 +1,13 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -10,82 +16,126 @@ import (
 	"github.com/rivo/tview"
 )
 
-const oathplateHelmetArt = `
-        @adJOJJcJJOJ                        
-     aaMMMaaaaaddOJc|                      
-     J@@@@aMaaaaaaddOcc @Mjj|              
-      MOOddaaMaaaddadddaOadJadj            
-      j|   @OadMaaaaddJadcOdjc|            
-            M OdaaaaadO@cO@@cJ-            
-               MaaaaaaO@ac@@JJ{            
-              cOaddaaad@@|@@cO{        >I  
-                 caaMaaM@Md@M@@        |"  
-          I|        a@@@@@@@@{        >c"  
-          {|{         |>-   "MM       jc   
-       |   dJ|I    >--|I  >I -@@{    j@O   
-       ||{{jaO|-I --ddOMJ I>I -@@d     a>  
-         JdaaMdOja-cdMdOaO     "ad@""> ""  
-          MMad{|{-{@MMMaJac|jj{>OcaJ>>>I-  
-         {cO@@@O|{-j@MdaMMc-{{{>|Od|I>I">" 
-          OdMaccc|{{a@dOOda{--{>-ddj">"|M> 
-             @@@ac{{cMMdddM@M{---Jac""|M@@O
-                @@O{{a@@@@@adaa-I{MO -M@J  
-                @@aj||I"{M@@@@@@M|Mdc@@d   
-                 @@OjddcI     -OM@@@c    I 
-                J{@@cMMdc@||>          "-- 
-               -jaM@a@@aJ@Occ{@>{{-I->c@@  
-             -|JacJd@@@@M@@c{d@J-|j{>-@O   
-             {j|-{jjjc@@@@@M|@@@j{|-I@@"   
-                 "{-c@@@@@@@a@@@@|{>@@@    
-                  >M@@@@@@@@@@@@@@|@@      
-                    adaM@@MMadJcjOj>       
-                     OOOJccjj{j>          
-
-`
-
-func RunTUI(initial AppState) error {
+// Layouts by terminal width: stacked below 100 columns, inputs with art
+// beside the report up to 160, then inputs, report and art side by side.
+const (
+	layoutNarrow = iota
+	layoutMedium
+	layoutWide
+)
+
+func layoutFor(width int) int {
+	switch {
+	case width < 100:
+		return layoutNarrow
+	case width < 160:
+		return layoutMedium
+	default:
+		return layoutWide
+	}
+}
+
+type TUIOptions struct {
+	AutoRefresh  time.Duration // 0 = manual fetches only
+	Notice       string        // shown in the status bar on start
+	FetchIfStale bool          // fetch on start when the cache is stale
+	NoMouse      bool          // don't capture the mouse
+}
+
+func RunTUI(initial AppState, opts TUIOptions) error {
 	app := tview.NewApplication()
 
-	tview.Styles.PrimitiveBackgroundColor = tcell.ColorBlack
-	tview.Styles.ContrastBackgroundColor = tcell.ColorBlack
-	tview.Styles.MoreContrastBackgroundColor = tcell.ColorBlack
-	tview.Styles.PrimaryTextColor = tcell.ColorWhite
-	tview.Styles.BorderColor = tcell.ColorGray
-	tview.Styles.SecondaryTextColor = tcell.ColorBlack
+	// colours: theme.json, switchable at runtime (see applyTheme)
+	theme, themeErr := loadTheme()
+	activeTheme = theme
 
 	state := initial
+	sortBy := sortKeys[0]
+	edits := newEditHistory(state.Overrides)
+
+	// key bindings: defaults plus the user's keymap file
+	km, kmErr := loadKeymap()
+
+	// auto-refresh is opt-in; countdown lives in the header
+	autoEvery := time.Duration(0)
+	if opts.AutoRefresh > 0 {
+		autoEvery = clampInterval(opts.AutoRefresh)
+	}
+	var nextRefresh time.Time
+	fetching := false // one fetch at a time
+	fetchDone, fetchTotal := 0, 0
+	var fetchCancel context.CancelFunc
+	defer func() {
+		if fetchCancel != nil {
+			fetchCancel()
+		}
+	}()
+	fetchFailures := 0
+	var fetchLog []fetchLogEntry
+	logFetchError := func(err error) {
+		fetchLog = append(fetchLog, fetchLogEntry{At: time.Now(), Err: err})
+		if len(fetchLog) > maxFetchLog {
+			fetchLog = fetchLog[len(fetchLog)-maxFetchLog:]
+		}
+	}
+	headerBase := ""
 
 	// --- widgets ---
 	header := tview.NewTextView()
 	header.SetDynamicColors(true)
 	header.SetTextAlign(tview.AlignCenter)
-	header.SetBackgroundColor(tcell.ColorBlack)
 
-	results := tview.NewTextView()
-	results.SetDynamicColors(true)
-	results.SetScrollable(true)
-	results.SetWrap(false)
+	// report panel: summary, armor table, detail pane for the selected row
+	summary := tview.NewTextView()
+	summary.SetDynamicColors(true)
+	summary.SetWrap(false)
+
+	reportTable := tview.NewTable()
+	reportTable.SetFixed(1, 1)
+	reportTable.SetSelectable(true, false)
+
+	detail := tview.NewTextView()
+	detail.SetDynamicColors(true)
+	detail.SetWrap(false)
+	detail.SetBorder(true)
+	detail.SetTitle("Detail (Enter: more)")
+
+	results := tview.NewFlex()
+	results.SetDirection(tview.FlexRow)
 	results.SetBorder(true)
 	results.SetTitle("Report")
-	results.SetBackgroundColor(tcell.ColorBlack)
-	results.SetBorderColor(tcell.ColorRed)
+	results.AddItem(summary, 6, 0, false)
+	results.AddItem(reportTable, 0, 1, false)
+	results.AddItem(detail, 0, 0, false) // shown while the table has focus
+
+	staleBanner := tview.NewTextView()
+	staleBanner.SetDynamicColors(true)
+	staleBanner.SetTextAlign(tview.AlignCenter)
+
+	// edit history side panel (hidden until toggled)
+	historyList := tview.NewList()
+	historyList.ShowSecondaryText(true)
+	historyList.SetBorder(true)
+	historyList.SetTitle("History (Enter: revert)")
+	historyList.SetHighlightFullLine(true)
+
+	cmdLine := tview.NewInputField()
+	cmdLine.SetLabel(":")
+	cmdLine.SetPlaceholder("set shale.low 29k | fetch | sort roi | export csv | Tab completes, ↑↓ history")
 
 	status := tview.NewTextView()
 	status.SetDynamicColors(true)
 	status.SetBorder(true)
-	status.SetBackgroundColor(tcell.ColorBlack)
 
 	help := tview.NewTextView()
 	help.SetDynamicColors(true)
-	help.SetText("Enter: apply field | F/L/S/Q: fetch/load/save/quit")
-	help.SetBackgroundColor(tcell.ColorBlack)
+	help.SetText(fmt.Sprintf("Enter: apply | ↑↓/Tab: move | %s: fetch | %s: save | %s: quit | %s: all keys",
+		km.Hint("fetch"), km.Hint("save"), km.Hint("quit"), km.Hint("help")))
 
 	// --- art panel (this is the missing block you nuked) ---
 	art := tview.NewTextView()
 	art.SetBorder(true)
 	art.SetTitle("Oathplate Helmet")
-	art.SetBackgroundColor(tcell.ColorBlack)
-	art.SetTextColor(tcell.ColorWhite)
 	art.SetWrap(false)
 	art.SetScrollable(true)
 	art.SetDynamicColors(false)
@@ -96,138 +146,471 @@ func RunTUI(initial AppState) error {
 	art.SetDynamicColors(false)
 	art.SetText(oathplateHelmetArt)
 
-	help.SetBackgroundColor(tcell.ColorBlack)
+	// price grid: one input per item × high/low/avg
+	gridRows := []struct{ label, target string }{
+		{"Shale", "shale"},
+		{"Shards", "shard"},
+		{"Helmet", "armor1"},
+		{"Chest", "armor2"},
+		{"Legs", "armor3"},
+	}
+	gridCols := []string{"high", "low", "avg"}
+
+	type priceCell struct {
+		in                *tview.InputField
+		target, component string
+	}
+	var cells []priceCell // row-major, len(gridRows)*len(gridCols)
+
+	priceGrid := tview.NewGrid()
+	priceGrid.SetColumns(8, 0, 0, 0)
+	gridLabels := []*tview.TextView{tview.NewTextView()} // corner, column and row headers
+	priceGrid.AddItem(gridLabels[0], 0, 0, 1, 1, 0, 0, false)
+	for c, comp := range gridCols {
+		h := tview.NewTextView().SetText(strings.ToUpper(comp))
+		priceGrid.AddItem(h, 0, c+1, 1, 1, 0, 0, false)
+		gridLabels = append(gridLabels, h)
+	}
+	for r, row := range gridRows {
+		l := tview.NewTextView().SetText(row.label)
+		priceGrid.AddItem(l, r+1, 0, 1, 1, 0, 0, false)
+		gridLabels = append(gridLabels, l)
+		for c, comp := range gridCols {
+			in := tview.NewInputField()
+			priceGrid.AddItem(in, r+1, c+1, 1, 1, 0, 0, r == 0 && c == 0)
+			cells = append(cells, priceCell{in: in, target: row.target, component: comp})
+		}
+	}
 
-	inShale := tview.NewInputField().SetLabel("Shale avg: ")
-	inShard := tview.NewInputField().SetLabel("Shard avg: ")
-	inA1 := tview.NewInputField().SetLabel("Helmet avg: ")
-	inA2 := tview.NewInputField().SetLabel("Chest avg: ")
-	inA3 := tview.NewInputField().SetLabel("Legs avg: ")
+	autoAvg := tview.NewCheckbox().SetLabel(fmt.Sprintf("Auto avg hi/lo (%s) ", km.Hint("auto-avg")))
 
-	btnFetch := tview.NewButton("Fetch (F)")
-	btnLoad := tview.NewButton("Load (L)")
-	btnSave := tview.NewButton("Save (S)")
-	btnQuit := tview.NewButton("Quit (Q)")
+	btnFetch := tview.NewButton(fmt.Sprintf("Fetch (%s)", km.Hint("fetch")))
+	btnLoad := tview.NewButton(fmt.Sprintf("Load (%s)", km.Hint("load")))
+	btnSave := tview.NewButton(fmt.Sprintf("Save (%s)", km.Hint("save")))
+	btnQuit := tview.NewButton(fmt.Sprintf("Quit (%s)", km.Hint("quit")))
 
 	// --- helpers ---
-	setStatus := func(msg string) { status.SetText(msg) }
+	setStatus := func(msg string) { status.SetText(activeTheme.Tags(msg)) }
 
 	styleInput := func(in *tview.InputField) {
-		in.SetFieldBackgroundColor(tcell.ColorBlack)
-		in.SetFieldTextColor(tcell.ColorWhite)
-		in.SetLabelColor(tcell.ColorGray)
-		in.SetBackgroundColor(tcell.ColorBlack) // affects surrounding primitive area
+		th := activeTheme
+		in.SetFieldBackgroundColor(th.Background)
+		in.SetFieldTextColor(th.Text)
+		in.SetLabelColor(th.Muted)
+		in.SetPlaceholderTextColor(th.Muted)
+		in.SetBackgroundColor(th.Background) // affects surrounding primitive area
 	}
 
 	styleButton := func(b *tview.Button) {
-		b.SetLabelColor(tcell.ColorWhite)
-		b.SetLabelColorActivated(tcell.ColorWhite)
-		b.SetBackgroundColor(tcell.ColorBlack)
+		th := activeTheme
+		b.SetLabelColor(th.Text)
+		b.SetLabelColorActivated(th.Background)
+		b.SetBackgroundColor(th.Background)
+		b.SetBackgroundColorActivated(th.Selected)
 	}
 
-	styleInput(inShale)
-	styleInput(inShard)
-	styleInput(inA1)
-	styleInput(inA2)
-	styleInput(inA3)
-	styleButton(btnFetch)
-	styleButton(btnLoad)
-	styleButton(btnSave)
-	styleButton(btnQuit)
+	styleBox := func(b *tview.Box, border tcell.Color) {
+		b.SetBackgroundColor(activeTheme.Background)
+		b.SetBorderColor(border)
+		b.SetTitleColor(activeTheme.Text)
+	}
 
-	refresh := func() {
+	styleList := func(l *tview.List) {
+		th := activeTheme
+		styleBox(l.Box, th.Accent)
+		l.SetMainTextColor(th.Text)
+		l.SetSecondaryTextColor(th.Muted)
+		l.SetSelectedTextColor(th.Background)
+		l.SetSelectedBackgroundColor(th.Selected)
+	}
+
+	updateHeader := func() {
+		switch {
+		case fetching:
+			header.SetText(fmt.Sprintf("%s — fetching %d/%d", headerBase, fetchDone, fetchTotal))
+		case autoEvery == 0:
+			header.SetText(headerBase)
+		default:
+			left := time.Until(nextRefresh).Truncate(time.Second)
+			header.SetText(fmt.Sprintf("%s — auto-refresh in %s", headerBase, max(left, 0)))
+		}
+	}
+
+	currentReport := func() Report {
 		rep := ComputeReport(state)
-		header.SetText(fmt.Sprintf("OathPlate Calculator %s — %s", rep.Version, strings.ToUpper(rep.Mode)))
-		results.SetText(RenderReportString(rep))
+		rep.SortBy = sortBy
+		return rep
+	}
+
+	var screen tcell.Screen       // set on the first draw; the clipboard writes to it
+	var tableArmors []ArmorReport // armors in table row order
+	var lastReport Report
+	detailShown := false
+
+	showDetail := func(row int) {
+		if row < 1 || row > len(tableArmors) {
+			return
+		}
+		detail.SetText(activeTheme.Tags(renderArmorDetail(tableArmors[row-1], lastReport)))
+	}
+
+	var updateStale func()
+	refresh := func() {
+		rep := currentReport()
+		lastReport = rep
+		headerBase = fmt.Sprintf("OathPlate Calculator %s — %s", rep.Version, strings.ToUpper(rep.Mode))
+		if rep.Profile != defaultProfileName {
+			headerBase += " — profile " + rep.Profile
+		}
+		if n := len(rep.Overrides); n > 0 {
+			headerBase += fmt.Sprintf(" + %d override(s)", n)
+		}
+		updateHeader()
+		updateStale()
+		summary.SetText(activeTheme.Tags(renderSummaryTagged(rep)))
+		results.ResizeItem(summary, strings.Count(summary.GetText(false), "\n")+1, 0)
+		tableArmors = fillReportTable(reportTable, rep)
+		if row, _ := reportTable.GetSelection(); row < 1 || row > len(tableArmors) {
+			reportTable.Select(1, 0)
+		}
+		if detailShown {
+			row, _ := reportTable.GetSelection()
+			showDetail(row)
+		}
 
 		// Don't overwrite an "Applied ..." message during manual entry.
 		// Only show fetch age when the current state came from a fetch.
-		if state.Mode == "fetch" && !state.FetchedAt.IsZero() {
-			age := time.Since(state.FetchedAt)
-			setStatus(fmt.Sprintf("Fetched: %s | Age: %s | TTL: 20m",
+		if (state.Mode == "api" || state.Mode == "offline") && !state.FetchedAt.IsZero() {
+			age, _ := stateAge(state)
+			setStatus(fmt.Sprintf("Fetched: %s | Age: %s | TTL: %s",
 				state.FetchedAt.Local().Format("2006-01-02 15:04:05"),
 				roundDuration(age),
+				roundDuration(cacheTTL),
 			))
 		}
 
-		// keep inputs in sync with state (avg)
-		inShale.SetText(fmt.Sprintf("%d", state.Shale.Avg))
-		inShard.SetText(fmt.Sprintf("%d", state.Shard.Avg))
-		if len(state.Armors) >= 3 {
-			inA1.SetText(fmt.Sprintf("%d", state.Armors[0].Price.Avg))
-			inA2.SetText(fmt.Sprintf("%d", state.Armors[1].Price.Avg))
-			inA3.SetText(fmt.Sprintf("%d", state.Armors[2].Price.Avg))
+		// keep the grid in sync with state (overrides applied): manual
+		// values in yellow, rows with low > high on red
+		eff := effectiveState(state, time.Now())
+		for _, c := range cells {
+			t, err := fieldTriple(&eff, c.target)
+			if err != nil {
+				continue
+			}
+			v := map[string]int64{"high": t.High, "low": t.Low, "avg": t.Avg}[c.component]
+			c.in.SetText(fmt.Sprintf("%d", v))
+
+			fg, bg := activeTheme.Text, activeTheme.Background
+			if rep.IsManual(c.target, c.component) {
+				fg = activeTheme.Manual
+			}
+			if t.Low > t.High && c.component != "avg" {
+				bg = activeTheme.Warning
+			}
+			c.in.SetFieldTextColor(fg)
+			c.in.SetFieldBackgroundColor(bg)
+		}
+	}
+
+	// updateHistory redraws the history panel: newest first, the current
+	// point marked, redo-able edits greyed out.
+	var revertTo func(i int)
+	updateHistory := func() {
+		historyList.Clear()
+		entries := edits.Entries()
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+			text := e.String()
+			if i == 0 {
+				text = "start"
+			}
+			switch {
+			case i == edits.Pos():
+				text = "▶ " + text
+			case i > edits.Pos():
+				text = "[gray]  " + text + "[-]"
+			default:
+				text = "  " + text
+			}
+			historyList.AddItem(activeTheme.Tags(text), "  "+e.At.Local().Format("15:04:05"), 0, func() { revertTo(i) })
 		}
+		historyList.SetCurrentItem(len(entries) - 1 - edits.Pos())
+	}
+
+	// recordEdit snapshots the override layer after a manual change.
+	recordEdit := func(field, before, after string) {
+		edits.Record(field, before, after, state.Overrides)
+		updateHistory()
+	}
+
+	resetEdits := func() {
+		edits.Reset(state.Overrides)
+		updateHistory()
 	}
 
+	restoreOverrides := func(overrides []PriceOverride) {
+		state.Overrides = overrides
+		refresh()
+		updateHistory()
+	}
+
+	revertTo = func(i int) {
+		if o, ok := edits.Goto(i); ok {
+			restoreOverrides(o)
+			setStatus(fmt.Sprintf("Reverted to %s", edits.Entries()[i].At.Local().Format("15:04:05")))
+		}
+	}
+
+	doUndo := func() {
+		e, o, ok := edits.Undo()
+		if !ok {
+			setStatus("Nothing to undo.")
+			return
+		}
+		restoreOverrides(o)
+		setStatus("Undid " + e.String())
+	}
+
+	doRedo := func() {
+		e, o, ok := edits.Redo()
+		if !ok {
+			setStatus("Nothing to redo.")
+			return
+		}
+		restoreOverrides(o)
+		setStatus("Redid " + e.String())
+	}
+
+	// invalidRows names the grid rows whose low is above their high.
+	invalidRows := func() []string {
+		eff := effectiveState(state, time.Now())
+		var bad []string
+		for _, row := range gridRows {
+			if t, err := fieldTriple(&eff, row.target); err == nil && t.Low > t.High {
+				bad = append(bad, row.label)
+			}
+		}
+		return bad
+	}
+
+	// apply records an override: "125k", or "125k@2h" to expire it after
+	// two hours. An empty field drops the override again.
 	apply := func(field, text string) bool {
-		v, err := parseGP(text)
+		text = strings.TrimSpace(text)
+		before := fieldValue(state, field)
+		if text == "" {
+			n := ClearOverride(&state, field)
+			if n > 0 {
+				recordEdit(field, before, fieldValue(state, field))
+			}
+			refresh()
+			setStatus(fmt.Sprintf("[green]Cleared[-] %d override(s) on %s", n, field))
+			return true
+		}
+
+		valText, ttlText, hasTTL := strings.Cut(text, "@")
+		var expires time.Time
+		if hasTTL {
+			d, err := time.ParseDuration(strings.TrimSpace(ttlText))
+			if err != nil || d <= 0 {
+				setStatus(fmt.Sprintf("[red]Invalid[-] expiry %q (try 125k@2h, 125k@30m)", ttlText))
+				return false
+			}
+			expires = time.Now().Add(d)
+		}
+
+		v, err := parseGP(valText)
 		if err != nil {
 			setStatus(fmt.Sprintf("[red]Invalid[-] %s (try 125k, 1.25m, 1,250,000)", field))
 			return false
 		}
-		if err := ApplyManualSet(&state, field, v); err != nil {
+		if err := ApplyManualSetUntil(&state, field, v, expires); err != nil {
 			setStatus(fmt.Sprintf("[red]Set failed[-]: %v", err))
 			return false
 		}
-		state.Mode = "manual"
+
+		// with auto avg on, a high/low edit re-derives the avg override
+		target, component, _ := strings.Cut(field, ".")
+		derived := ""
+		if autoAvg.IsChecked() && (component == "high" || component == "low") {
+			eff := effectiveState(state, time.Now())
+			if t, err := fieldTriple(&eff, canonicalTarget(target)); err == nil && t.High > 0 && t.Low > 0 {
+				avg := (t.High + t.Low) / 2
+				_ = ApplyManualSetUntil(&state, target+".avg", avg, expires)
+				derived = fmt.Sprintf(", avg → %s", formatGPShort(avg))
+			}
+		}
+
+		recordEdit(field, before, fieldValue(state, field))
 		refresh()
-		setStatus(fmt.Sprintf("[green]Applied[-] %s = %s", field, formatGPShort(v)))
+		msg := fmt.Sprintf("[green]Applied[-] %s = %s (manual)%s", field, formatGPShort(v), derived)
+		if hasTTL {
+			msg += ", expires " + expires.Local().Format("15:04:05")
+		}
+		if bad := invalidRows(); len(bad) > 0 {
+			msg += fmt.Sprintf(" | [red]low > high:[-] %s", strings.Join(bad, ", "))
+		}
+		setStatus(msg)
 		return true
 	}
 
-	// Enter-to-apply
-	inShale.SetDoneFunc(func(k tcell.Key) {
-		if k == tcell.KeyEnter && apply("shale.avg", inShale.GetText()) {
-			app.SetFocus(inShard)
-		}
-	})
-	inShard.SetDoneFunc(func(k tcell.Key) {
-		if k == tcell.KeyEnter && apply("shard.avg", inShard.GetText()) {
-			app.SetFocus(inA1)
+	doClearOverrides := func() {
+		n := ClearOverride(&state, "")
+		if n > 0 {
+			recordEdit("overrides", fmt.Sprint(n), "0")
 		}
-	})
-	inA1.SetDoneFunc(func(k tcell.Key) {
-		if k == tcell.KeyEnter && apply("armor1.avg", inA1.GetText()) {
-			app.SetFocus(inA2)
+		refresh()
+		setStatus(fmt.Sprintf("[green]Cleared[-] %d override(s); showing fetched prices.", n))
+	}
+
+	// Enter applies and moves on (row-major, wrapping); Tab/Backtab move
+	// without applying; Up/Down move between rows.
+	for i, c := range cells {
+		field := c.target + "." + c.component
+		in := c.in
+		next := cells[(i+1)%len(cells)].in
+		prev := cells[(i+len(cells)-1)%len(cells)].in
+		in.SetDoneFunc(func(k tcell.Key) {
+			switch k {
+			case tcell.KeyEnter:
+				if apply(field, in.GetText()) {
+					app.SetFocus(next)
+				}
+			case tcell.KeyTab:
+				app.SetFocus(next)
+			case tcell.KeyBacktab:
+				app.SetFocus(prev)
+			}
+		})
+		up := cells[(i+len(cells)-len(gridCols))%len(cells)].in
+		down := cells[(i+len(gridCols))%len(cells)].in
+		in.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+			switch ev.Key() {
+			case tcell.KeyUp:
+				app.SetFocus(up)
+				return nil
+			case tcell.KeyDown:
+				app.SetFocus(down)
+				return nil
+			}
+			return ev
+		})
+	}
+
+	// actions
+	// doFetch skips the network while the cache is fresh unless forced.
+	// Only one fetch runs at a time; cancel-fetch stops it.
+	doFetch := func(force bool) {
+		if fetching {
+			setStatus(fmt.Sprintf("Already fetching (%d/%d); %s to cancel.", fetchDone, fetchTotal, km.Hint("cancel-fetch")))
+			return
 		}
-	})
-	inA2.SetDoneFunc(func(k tcell.Key) {
-		if k == tcell.KeyEnter && apply("armor2.avg", inA2.GetText()) {
-			app.SetFocus(inA3)
+		if age, fresh := stateAge(state); fresh && !force {
+			setStatus(fmt.Sprintf("Prices are fresh (%s old, TTL %s). %s to fetch anyway.",
+				roundDuration(age), roundDuration(cacheTTL), km.Hint("force-fetch")))
+			return
 		}
-	})
-	inA3.SetDoneFunc(func(k tcell.Key) {
-		if k == tcell.KeyEnter && apply("armor3.avg", inA3.GetText()) {
-			app.SetFocus(inShale) // wrap
+		ctx, cancel := context.WithCancel(context.Background())
+		if force {
+			ctx = withRevalidate(ctx) // ask the wiki even for fresh HTTP cache entries
 		}
-	})
-
-	// actions
-	doFetch := func() {
-		setStatus("Fetching...")
+		fetching, fetchCancel = true, cancel
+		fetchDone, fetchTotal = 0, len(fetchItems())
+		setStatus(fmt.Sprintf("Fetching... (%s to cancel)", km.Hint("cancel-fetch")))
+		updateHeader()
+		prev := state
 		go func() {
-			s, err := FetchStateFromAPI()
+			s, err := FetchStateFromAPIContext(ctx, prev, func(p FetchProgress) {
+				app.QueueUpdateDraw(func() {
+					fetchDone = p.Done
+					updateHeader()
+					if p.Err != nil && !errors.Is(p.Err, context.Canceled) {
+						logFetchError(p.Err)
+					}
+					if p.Err == nil {
+						setStatus(fmt.Sprintf("Fetching... %d of %d items (%s to cancel)", p.Done, p.Total, km.Hint("cancel-fetch")))
+					}
+				})
+			})
+			cancel()
 			app.QueueUpdateDraw(func() {
+				fetching, fetchCancel = false, nil
+				cancelled := errors.Is(err, context.Canceled)
+				switch {
+				case cancelled:
+				case err != nil:
+					fetchFailures++
+				default:
+					fetchFailures = 0
+				}
+				if autoEvery > 0 {
+					nextRefresh = time.Now().Add(backoffDelay(autoEvery, fetchFailures))
+				}
+				updateHeader()
+
+				if cancelled {
+					setStatus("Fetch cancelled; prices unchanged.")
+					return
+				}
 				if err != nil {
-					setStatus(fmt.Sprintf("[red]Fetch failed[-]: %v", err))
+					var fe *FetchError
+					if !errors.As(err, &fe) {
+						logFetchError(err) // item failures are logged as they happen
+					}
+					setStatus(fmt.Sprintf("[red]Fetch failed[-]: %v (%s: error log)", err, km.Hint("errors")))
 					return
 				}
-				state = s
-				_ = saveCache(state)
-				setStatus("[green]Fetched and cached.[-]")
+				_ = appendHistory(s)
+				state = withOverridesFrom(s, state)
+				if err := saveCache(state); err != nil {
+					setStatus(fmt.Sprintf("[yellow]Fetched, not cached[-]: %v", err))
+				} else if len(s.Stale) > 0 {
+					setStatus(fmt.Sprintf("[yellow]Fetched with failures[-]: kept earlier prices for %s (%s: error log)",
+						strings.Join(s.Stale, ", "), km.Hint("errors")))
+				} else {
+					setStatus("[green]Fetched and cached.[-]")
+				}
 				refresh()
 			})
 		}()
 	}
 
+	toggleAuto := func() {
+		if autoEvery > 0 {
+			autoEvery = 0
+			setStatus("Auto-refresh off.")
+		} else {
+			autoEvery = clampInterval(opts.AutoRefresh)
+			if opts.AutoRefresh == 0 {
+				autoEvery = defaultPollInterval
+			}
+			nextRefresh = time.Now().Add(autoEvery)
+			setStatus(fmt.Sprintf("Auto-refresh every %s.", autoEvery))
+		}
+		updateHeader()
+	}
+
+	toggleAutoAvg := func() {
+		autoAvg.SetChecked(!autoAvg.IsChecked())
+		if autoAvg.IsChecked() {
+			setStatus("Auto avg on: editing high or low sets avg.")
+		} else {
+			setStatus("Auto avg off.")
+		}
+	}
+
 	doLoad := func() {
-		if c, ok := loadCache(); ok {
+		c, err := loadCache()
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			setStatus("[red]No cache found.[-]")
+		case err != nil:
+			setStatus(fmt.Sprintf("[red]Load failed[-]: %v", err))
+		default:
 			state = c.State
 			setStatus("[green]Loaded cache.[-]")
 			refresh()
-		} else {
-			setStatus("[red]No cache found.[-]")
+			resetEdits()
 		}
 	}
 
@@ -241,66 +624,810 @@ func RunTUI(initial AppState) error {
 
 	doQuit := func() { app.Stop() }
 
-	btnFetch.SetSelectedFunc(doFetch)
+	btnFetch.SetSelectedFunc(func() { doFetch(false) })
 	btnLoad.SetSelectedFunc(doLoad)
 	btnSave.SetSelectedFunc(doSave)
 	btnQuit.SetSelectedFunc(doQuit)
 
 	// --- layout ---
+	// applyLayout rebuilds left and body for the terminal width; it runs
+	// from the before-draw hook whenever the width crosses a breakpoint.
 	left := tview.NewFlex()
 	left.SetDirection(tview.FlexRow)
 	left.SetBorder(true)
 	left.SetTitle("Inputs")
 
-	left.AddItem(help, 1, 0, false)
+	body := tview.NewFlex()
+	spacer := tview.NewBox()
 
-	left.AddItem(inShale, 1, 0, true)
-	left.AddItem(inShard, 1, 0, false)
-	left.AddItem(inA1, 1, 0, false)
-	left.AddItem(inA2, 1, 0, false)
-	left.AddItem(inA3, 1, 0, false)
+	layoutMode := -1
+	artShown := true
+	historyShown := false
+	const leftRows = 2 + 1 + 7 + 1 + 4 // border, help, grid + auto avg, spacer, buttons
 
-	left.AddItem(tview.NewBox(), 1, 0, false) // spacer
+	historySize := func() int {
+		switch {
+		case !historyShown:
+			return 0
+		case layoutMode == layoutNarrow:
+			return 12 // rows under the report
+		default:
+			return 36 // columns beside it
+		}
+	}
 
-	left.AddItem(btnFetch, 1, 0, false)
-	left.AddItem(btnLoad, 1, 0, false)
-	left.AddItem(btnSave, 1, 0, false)
-	left.AddItem(btnQuit, 1, 0, false)
+	applyLayout := func() {
+		left.Clear()
+		left.AddItem(help, 1, 0, false)
+		left.AddItem(priceGrid, len(gridRows)+1, 0, true)
+		left.AddItem(autoAvg, 1, 0, false)
+		left.AddItem(spacer, 1, 0, false)
+		left.AddItem(btnFetch, 1, 0, false)
+		left.AddItem(btnLoad, 1, 0, false)
+		left.AddItem(btnSave, 1, 0, false)
+		left.AddItem(btnQuit, 1, 0, false)
 
-	// Art lives in the leftover space.
-	left.AddItem(art, 0, 1, false)
+		body.Clear()
+		switch layoutMode {
+		case layoutNarrow:
+			body.SetDirection(tview.FlexRow)
+			body.AddItem(left, leftRows, 0, true)
+			body.AddItem(results, 0, 1, false)
+		case layoutMedium:
+			// Art lives in the leftover space.
+			if artShown {
+				left.AddItem(art, 0, 1, false)
+			}
+			body.SetDirection(tview.FlexColumn)
+			body.AddItem(left, 0, 1, true)
+			body.AddItem(results, 0, 2, false)
+		default:
+			body.SetDirection(tview.FlexColumn)
+			body.AddItem(left, 0, 1, true)
+			body.AddItem(results, 0, 3, false)
+			if artShown {
+				body.AddItem(art, 48, 0, false) // fits the widest art
+			}
+		}
+		body.AddItem(historyList, historySize(), 0, false)
+	}
 
-	body := tview.NewFlex()
-	body.AddItem(left, 0, 1, true)
-	body.AddItem(results, 0, 2, false)
+	toggleArt := func() {
+		artShown = !artShown
+		applyLayout()
+		if layoutMode == layoutNarrow {
+			setStatus("Art is hidden on narrow terminals.")
+		}
+	}
+
+	// art follows the armor selected in the report table
+	showArt := func(itemID int) {
+		if a, ok := armorArt[itemID]; ok {
+			art.SetTitle(a.Title)
+			art.SetText(a.Art)
+		}
+	}
+
+	// the detail pane follows the table selection while the table has
+	// focus; Enter opens the armor page, Esc goes back to the price grid.
+	setDetailShown := func(on bool) {
+		detailShown = on
+		if on {
+			results.ResizeItem(detail, len(reportColumns)+1, 0)
+			row, _ := reportTable.GetSelection()
+			showDetail(row)
+		} else {
+			results.ResizeItem(detail, 0, 0)
+		}
+	}
+	reportTable.SetFocusFunc(func() { setDetailShown(true) })
+	reportTable.SetBlurFunc(func() { setDetailShown(false) })
+	reportTable.SetSelectionChangedFunc(func(row, _ int) {
+		if row >= 1 && row <= len(tableArmors) {
+			showArt(tableArmors[row-1].ItemID)
+		}
+		if detailShown {
+			showDetail(row)
+		}
+	})
+	reportTable.SetDoneFunc(func(k tcell.Key) {
+		switch k {
+		case tcell.KeyEscape, tcell.KeyTab, tcell.KeyBacktab:
+			app.SetFocus(cells[0].in)
+		}
+	})
+
+	toggleHistory := func() {
+		historyShown = !historyShown
+		body.ResizeItem(historyList, historySize(), 0)
+		if historyShown {
+			updateHistory()
+			app.SetFocus(historyList)
+			return
+		}
+		if historyList.HasFocus() {
+			app.SetFocus(cells[0].in)
+		}
+	}
 
 	root := tview.NewFlex()
 	root.SetDirection(tview.FlexRow)
 	root.AddItem(header, 1, 0, false)
+	root.AddItem(staleBanner, 0, 0, false)
 	root.AddItem(body, 0, 1, true)
-	root.AddItem(status, 1, 0, false)
+	root.AddItem(cmdLine, 0, 0, false) // one row while the command line is open
+	root.AddItem(status, 3, 0, false)  // bordered: one line of text
 
-	// global hotkeys
-	root.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
-		switch ev.Rune() {
-		case 'q', 'Q':
-			doQuit()
+	// scenario comparison page (V toggles)
+	compareView := tview.NewTextView()
+	compareView.SetDynamicColors(false)
+	compareView.SetScrollable(true)
+	compareView.SetWrap(false)
+	compareView.SetBorder(true)
+	compareView.SetTitle(fmt.Sprintf("Scenarios (%s/Esc: back)", km.Keys("scenarios")))
+
+	pages := tview.NewPages()
+	pages.AddPage("main", root, true, true)
+	pages.AddPage("compare", compareView, true, false)
+
+	// armor page: full drill-down for the selected row. Market data loads
+	// in the background and redraws the page if it is still open.
+	armorView := tview.NewTextView()
+	armorView.SetDynamicColors(true)
+	armorView.SetScrollable(true)
+	armorView.SetWrap(false)
+	armorView.SetBorder(true)
+	armorView.SetTitle("Armor (Esc: back)")
+	pages.AddPage("armor", armorView, true, false)
+
+	armorShown := 0 // item ID on the armor page, 0 when closed
+	openArmorPage := func(row int) {
+		if row < 1 || row > len(tableArmors) {
+			return
+		}
+		a, rep := tableArmors[row-1], lastReport
+		hist, err := loadHistory()
+		if err != nil {
+			setStatus(fmt.Sprintf("[red]History[-]: %v", err))
+		}
+		render := func(m *MarketInfo, mErr error) {
+			armorView.SetText(activeTheme.Tags(renderArmorPage(a, rep, activeProfile, hist, m, mErr)))
+		}
+		render(nil, nil)
+		armorView.SetTitle("Armor (Esc: back)")
+		armorView.ScrollToBeginning()
+		armorShown = a.ItemID
+		pages.SwitchToPage("armor")
+
+		go func() {
+			m, err := LookupMarketInfo(a.ItemID)
+			app.QueueUpdateDraw(func() {
+				if armorShown == a.ItemID {
+					render(&m, err)
+				}
+			})
+		}()
+	}
+	reportTable.SetSelectedFunc(func(row, _ int) { openArmorPage(row) })
+
+	// mouse: a click selects an armor (and shows the detail pane), a double
+	// click opens its page, the wheel moves the selection
+	reportTable.SetMouseCapture(func(action tview.MouseAction, ev *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		row, _ := reportTable.GetSelection()
+		switch action {
+		case tview.MouseLeftDoubleClick:
+			if r, _ := reportTable.CellAt(ev.Position()); r >= 1 {
+				openArmorPage(r)
+			}
+			return action, nil
+		case tview.MouseScrollUp:
+			if row > 1 {
+				reportTable.Select(row-1, 0)
+			}
+			return action, nil
+		case tview.MouseScrollDown:
+			if row < len(tableArmors) {
+				reportTable.Select(row+1, 0)
+			}
+			return action, nil
+		}
+		return action, ev
+	})
+
+	// selectedArmor is the armor on the armor page, else the one selected
+	// in the report table.
+	selectedArmor := func() (ArmorReport, bool) {
+		if len(tableArmors) == 0 {
+			return ArmorReport{}, false
+		}
+		for _, a := range tableArmors {
+			if a.ItemID == armorShown {
+				return a, true
+			}
+		}
+		row, _ := reportTable.GetSelection()
+		return tableArmors[min(max(row, 1), len(tableArmors))-1], true
+	}
+
+	// doCopy puts one of copyTargets on the clipboard (OSC 52).
+	doCopy := func(what string) {
+		basis := cmp.Or(lastReport.SaleBasis, "avg")
+		a, ok := selectedArmor()
+		var text, desc string
+		switch {
+		case what == "report":
+			text, desc = RenderReportString(lastReport), "report"
+		case what == "breakeven":
+			text = breakEvenClip(lastReport, activeProfile)
+			desc = fmt.Sprintf("break-even price @%s (%s)", basis, text)
+		case !ok && (what == "armor" || what == "sale"):
+			setStatus("[red]Nothing to copy[-]: no armor in the report")
+			return
+		case what == "armor":
+			text, desc = armorClipLine(a, lastReport), a.Name
+		case what == "sale":
+			text = strconv.FormatInt(salePriceForLabel(a, basis), 10)
+			desc = fmt.Sprintf("%s sale price @%s (%s)", a.Name, basis, text)
+		default:
+			setStatus(fmt.Sprintf("[red]Copy what?[-] %s", paletteUsage("copy")))
+			return
+		}
+		if err := copyToClipboard(screen, text); err != nil {
+			setStatus(fmt.Sprintf("[red]Copy failed[-]: %v", err))
+			return
+		}
+		setStatus(fmt.Sprintf("[green]Copied[-] %s", desc))
+		if armorShown != 0 {
+			armorView.SetTitle("Armor (Esc: back) — copied " + desc)
+		}
+	}
+	copyActions := map[string]string{"copy-report": "report", "copy-armor": "armor", "copy-breakeven": "breakeven"}
+
+	armorView.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		if ev.Key() == tcell.KeyEscape {
+			armorShown = 0
+			pages.SwitchToPage("main")
+			app.SetFocus(reportTable)
 			return nil
-		case 'f', 'F':
-			doFetch()
+		}
+		if action, ok := km.Lookup(ev, false); ok && copyActions[action] != "" {
+			doCopy(copyActions[action])
 			return nil
-		case 'l', 'L':
-			doLoad()
+		}
+		return ev
+	})
+	focusReport := func() { app.SetFocus(reportTable) }
+
+	showCompare := func() {
+		names, err := listScenarios()
+		if err != nil {
+			setStatus(fmt.Sprintf("[red]Scenarios[-]: %v", err))
+			return
+		}
+		rs, err := scenarioReports(state, append([]string{currentScenario}, names...))
+		if err != nil {
+			setStatus(fmt.Sprintf("[red]Scenarios[-]: %v", err))
+			return
+		}
+		text := RenderComparisonString(rs)
+		if len(names) == 0 {
+			text += "\nNo saved scenarios yet. Save one with:\n  oathplateCalculator scenario save shards-10 shard-10%\n"
+		}
+		compareView.SetText(text)
+		compareView.ScrollToBeginning()
+		pages.SwitchToPage("compare")
+	}
+
+	// profile menu (P)
+	profileList := tview.NewList()
+	profileList.ShowSecondaryText(true)
+	profileList.SetBorder(true)
+	profileList.SetTitle("Profiles (Enter: switch, Esc: back)")
+	pages.AddPage("profiles", profileList, true, false)
+
+	switchProfile := func(name string) {
+		pages.SwitchToPage("main")
+		if err := selectProfile(name); err != nil {
+			setStatus(fmt.Sprintf("[red]Profile[-]: %v", err))
+			return
+		}
+		s, err := loadCachedState()
+		state = s
+		refresh()
+		resetEdits()
+		if err != nil {
+			setStatus(fmt.Sprintf("[red]Profile %s cache not loaded[-]: %v", name, err))
+			return
+		}
+		setStatus(fmt.Sprintf("[green]Profile[-] %s", activeProfile.describe()))
+	}
+
+	showProfiles := func() {
+		names, err := listProfiles()
+		if err != nil {
+			setStatus(fmt.Sprintf("[red]Profiles[-]: %v", err))
+			return
+		}
+		profileList.Clear()
+		for _, n := range names {
+			desc := "unreadable"
+			if p, err := loadProfile(n); err == nil {
+				desc = p.describe()
+			}
+			name := n
+			if n == activeProfile.Name {
+				name += " (active)"
+			}
+			profileList.AddItem(name, desc, 0, func() { switchProfile(n) })
+			if n == activeProfile.Name {
+				profileList.SetCurrentItem(-1)
+			}
+		}
+		pages.SwitchToPage("profiles")
+	}
+
+	profileList.SetDoneFunc(func() { pages.SwitchToPage("main") })
+
+	compareView.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		action, _ := km.Lookup(ev, false)
+		if ev.Key() == tcell.KeyEscape || action == "scenarios" {
+			pages.SwitchToPage("main")
+			return nil
+		}
+		if action == "quit" {
+			app.Stop()
 			return nil
-		case 's', 'S':
+		}
+		return ev
+	})
+
+	// key binding overlay (help action)
+	keysView := tview.NewTextView()
+	keysView.SetScrollable(true)
+	keysView.SetWrap(false)
+	keysView.SetBorder(true)
+	keysView.SetTitle("Keys (Esc: back)")
+	pages.AddPage("keys", keysView, true, false)
+
+	showKeys := func() {
+		text := km.Describe()
+		text += "\nSingle-character keys are ignored while a price field has focus.\n"
+		if path, err := keymapPath(); err == nil {
+			text += "Rebind keys in " + path + ", e.g. {\"fetch\": [\"F5\", \"Ctrl+G\"]}\n"
+		}
+		keysView.SetText(text)
+		keysView.ScrollToBeginning()
+		pages.SwitchToPage("keys")
+	}
+
+	keysView.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		action, _ := km.Lookup(ev, false)
+		if ev.Key() == tcell.KeyEscape || action == "help" {
+			pages.SwitchToPage("main")
+			return nil
+		}
+		return ev
+	})
+
+	// fetch error log (E toggles)
+	errorsView := tview.NewTextView()
+	errorsView.SetDynamicColors(true)
+	errorsView.SetScrollable(true)
+	errorsView.SetWrap(false)
+	errorsView.SetBorder(true)
+	errorsView.SetTitle("Fetch errors (Esc: back)")
+	pages.AddPage("errors", errorsView, true, false)
+
+	showErrors := func() {
+		errorsView.SetText(activeTheme.Tags(renderFetchLog(fetchLog)))
+		errorsView.ScrollToEnd()
+		pages.SwitchToPage("errors")
+	}
+
+	errorsView.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		action, _ := km.Lookup(ev, false)
+		if ev.Key() == tcell.KeyEscape || action == "errors" {
+			pages.SwitchToPage("main")
+			return nil
+		}
+		return ev
+	})
+
+	cancelFetch := func() {
+		if fetchCancel != nil {
+			fetchCancel()
+			setStatus("Cancelling fetch...")
+		}
+	}
+
+	// command line (':'); verbs as in the CLI, see paletteCommands
+	var cmdHistory []string
+	cmdIndex := 0 // == len(cmdHistory) while editing a new line
+	var cmdReturn tview.Primitive
+
+	closeCommand := func() {
+		cmdLine.SetText("")
+		root.ResizeItem(cmdLine, 0, 0)
+		if cmdReturn != nil {
+			app.SetFocus(cmdReturn)
+		}
+	}
+
+	// clicking elsewhere closes the command line
+	cmdLine.SetBlurFunc(func() {
+		cmdLine.SetText("")
+		root.ResizeItem(cmdLine, 0, 0)
+	})
+
+	openCommand := func() {
+		if app.GetFocus() == cmdLine {
+			return
+		}
+		cmdReturn = app.GetFocus()
+		cmdIndex = len(cmdHistory)
+		root.ResizeItem(cmdLine, 1, 0)
+		app.SetFocus(cmdLine)
+	}
+
+	// applyTheme colours every widget from activeTheme and re-renders, so
+	// themes can be switched while running.
+	applyTheme := func() {
+		th := activeTheme
+		tview.Styles.PrimitiveBackgroundColor = th.Background
+		tview.Styles.ContrastBackgroundColor = th.Background
+		tview.Styles.MoreContrastBackgroundColor = th.Background
+		tview.Styles.PrimaryTextColor = th.Text
+		tview.Styles.BorderColor = th.Border
+		tview.Styles.TitleColor = th.Text
+		tview.Styles.SecondaryTextColor = th.Background
+
+		for _, tv := range []*tview.TextView{header, summary, status, help, art, compareView, keysView, errorsView, armorView} {
+			tv.SetBackgroundColor(th.Background)
+			tv.SetTextColor(th.Text)
+		}
+		for _, l := range gridLabels {
+			l.SetBackgroundColor(th.Background)
+			l.SetTextColor(th.Muted)
+		}
+		for _, b := range []*tview.Box{results.Box, compareView.Box, keysView.Box, errorsView.Box, armorView.Box} {
+			styleBox(b, th.Accent)
+		}
+		for _, b := range []*tview.Box{left.Box, body.Box, root.Box, spacer, detail.Box, status.Box, art.Box, priceGrid.Box, reportTable.Box} {
+			styleBox(b, th.Border)
+		}
+		detail.SetTextColor(th.Text)
+		results.SetBackgroundColor(th.Background)
+		staleBanner.SetBackgroundColor(th.Warning)
+		staleBanner.SetTextColor(th.Text)
+		reportTable.SetSelectedStyle(tcell.StyleDefault.Background(th.Selected).Foreground(th.Background))
+
+		for _, c := range cells {
+			styleInput(c.in)
+		}
+		styleInput(cmdLine)
+		cmdLine.SetLabelColor(th.Manual)
+		autoAvg.SetBackgroundColor(th.Background)
+		autoAvg.SetLabelColor(th.Muted)
+		autoAvg.SetFieldBackgroundColor(th.Background)
+		autoAvg.SetFieldTextColor(th.Text)
+		for _, b := range []*tview.Button{btnFetch, btnLoad, btnSave, btnQuit} {
+			styleButton(b)
+		}
+		styleList(historyList)
+		styleList(profileList)
+
+		refresh()
+		updateHistory()
+		if detailShown {
+			row, _ := reportTable.GetSelection()
+			showDetail(row)
+		}
+	}
+
+	setTheme := func(name string) {
+		if _, ok := builtinThemes[name]; !ok {
+			setStatus(fmt.Sprintf("[red]Unknown theme[-] %q (use %s)", name, strings.Join(themeNames(), ", ")))
+			return
+		}
+		activeTheme = themeNamed(name)
+		applyTheme()
+		setStatus("Theme " + name)
+	}
+
+	cycleTheme := func() {
+		names := themeNames()
+		i := slices.Index(names, activeTheme.Name)
+		setTheme(names[(i+1)%len(names)])
+	}
+
+	runCommand := func(line string) {
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			return
+		}
+		usageErr := func() { setStatus("[red]" + paletteUsage(args[0]) + "[-]") }
+
+		switch verb := args[0]; verb {
+		case "set":
+			if len(args) < 3 {
+				usageErr()
+				return
+			}
+			apply(args[1], strings.Join(args[2:], ""))
+		case "clear":
+			if len(args) != 2 {
+				usageErr()
+			} else if args[1] == "all" {
+				doClearOverrides()
+			} else {
+				apply(args[1], "")
+			}
+		case "fetch":
+			doFetch(len(args) > 1 && args[1] == "force")
+		case "load":
+			doLoad()
+		case "save":
 			doSave()
+		case "scenario":
+			if len(args) < 2 {
+				usageErr()
+				return
+			}
+			switch args[1] {
+			case "load":
+				if len(args) != 3 {
+					usageErr()
+					return
+				}
+				sc, err := loadScenario(args[2])
+				if err != nil {
+					setStatus(fmt.Sprintf("[red]Scenario[-]: %v", err))
+					return
+				}
+				state = sc.State
+				refresh()
+				resetEdits()
+				setStatus(fmt.Sprintf("[green]Loaded scenario[-] %s (saved %s); save to make it the cache",
+					sc.Name, sc.SavedAt.Local().Format("2006-01-02 15:04")))
+			case "save":
+				if len(args) < 3 {
+					usageErr()
+					return
+				}
+				sc, err := NewScenario(args[2], state, args[3:])
+				if err == nil {
+					err = saveScenario(sc)
+				}
+				if err != nil {
+					setStatus(fmt.Sprintf("[red]Scenario[-]: %v", err))
+					return
+				}
+				setStatus(fmt.Sprintf("[green]Saved scenario[-] %s", sc.Name))
+			case "compare":
+				showCompare()
+			default:
+				usageErr()
+			}
+		case "export":
+			if len(args) < 2 || len(args) > 3 {
+				usageErr()
+				return
+			}
+			out := "oathplate_report." + args[1]
+			if len(args) == 3 {
+				out = args[2]
+			}
+			body, err := exportReport(args[1], currentReport())
+			if err == nil {
+				err = os.WriteFile(out, body, 0o644)
+			}
+			if err != nil {
+				setStatus(fmt.Sprintf("[red]Export failed[-]: %v", err))
+				return
+			}
+			setStatus(fmt.Sprintf("[green]Wrote[-] %s", out))
+		case "sort":
+			if len(args) != 2 || !slices.Contains(sortKeys, args[1]) {
+				usageErr()
+				return
+			}
+			sortBy = args[1]
+			refresh()
+			setStatus(fmt.Sprintf("Sorted by %s", sortBy))
+		case "profile":
+			if len(args) == 1 {
+				showProfiles()
+			} else {
+				switchProfile(args[1])
+			}
+		case "undo":
+			doUndo()
+		case "redo":
+			doRedo()
+		case "history":
+			toggleHistory()
+		case "auto":
+			toggleAuto()
+		case "avg":
+			toggleAutoAvg()
+		case "copy":
+			if len(args) == 1 {
+				doCopy("report")
+			} else {
+				doCopy(args[1])
+			}
+		case "theme":
+			if len(args) == 1 {
+				cycleTheme()
+			} else {
+				setTheme(args[1])
+			}
+		case "keys", "help":
+			showKeys()
+		case "errors":
+			showErrors()
+		case "quit", "q":
+			doQuit()
+		default:
+			names := make([]string, len(paletteCommands))
+			for i, c := range paletteCommands {
+				names[i] = c.Name
+			}
+			setStatus(fmt.Sprintf("[red]Unknown command[-] %q (try %s)", verb, strings.Join(names, ", ")))
+		}
+	}
+
+	cmdLine.SetDoneFunc(func(k tcell.Key) {
+		switch k {
+		case tcell.KeyEnter:
+			line := strings.TrimSpace(cmdLine.GetText())
+			if line != "" && (len(cmdHistory) == 0 || cmdHistory[len(cmdHistory)-1] != line) {
+				cmdHistory = append(cmdHistory, line)
+			}
+			closeCommand()
+			runCommand(line)
+		case tcell.KeyEscape:
+			closeCommand()
+		case tcell.KeyTab:
+			line, matches := completeCommand(cmdLine.GetText())
+			cmdLine.SetText(line)
+			if len(matches) > 0 {
+				setStatus(strings.Join(matches, "  "))
+			}
+		}
+	})
+
+	// Up/Down walk the history
+	cmdLine.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyUp:
+			if cmdIndex > 0 {
+				cmdIndex--
+				cmdLine.SetText(cmdHistory[cmdIndex])
+			}
+			return nil
+		case tcell.KeyDown:
+			if cmdIndex < len(cmdHistory) {
+				cmdIndex++
+				if cmdIndex == len(cmdHistory) {
+					cmdLine.SetText("")
+				} else {
+					cmdLine.SetText(cmdHistory[cmdIndex])
+				}
+			}
 			return nil
 		}
 		return ev
 	})
 
-	refresh()
-	return app.SetRoot(root, true).Run()
+	actions := map[string]func(){
+		"fetch":           func() { doFetch(false) },
+		"force-fetch":     func() { doFetch(true) },
+		"load":            doLoad,
+		"save":            doSave,
+		"auto-refresh":    toggleAuto,
+		"clear-overrides": doClearOverrides,
+		"auto-avg":        toggleAutoAvg,
+		"scenarios":       showCompare,
+		"profiles":        showProfiles,
+		"report":          focusReport,
+		"art":             toggleArt,
+		"theme":           cycleTheme,
+		"undo":            doUndo,
+		"redo":            doRedo,
+		"history":         toggleHistory,
+		"copy-report":     func() { doCopy("report") },
+		"copy-armor":      func() { doCopy("armor") },
+		"copy-breakeven":  func() { doCopy("breakeven") },
+		"command":         openCommand,
+		"errors":          showErrors,
+		"cancel-fetch":    cancelFetch,
+		"help":            showKeys,
+		"quit":            doQuit,
+	}
+
+	// global hotkeys; plain characters stay with the input that has focus
+	root.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		_, typing := app.GetFocus().(*tview.InputField)
+		if action, ok := km.Lookup(ev, typing); ok {
+			if action == "cancel-fetch" && (!fetching || app.GetFocus() == cmdLine) {
+				return ev // Esc keeps its usual meaning
+			}
+			actions[action]()
+			return nil
+		}
+		return ev
+	})
+
+	// one-second tick drives the countdown and fires due auto-refreshes
+	done := make(chan struct{})
+	defer close(done)
+	if autoEvery > 0 {
+		nextRefresh = time.Now().Add(autoEvery)
+	}
+	go func() {
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				app.QueueUpdateDraw(func() {
+					if autoEvery > 0 && !fetching && !time.Now().Before(nextRefresh) {
+						doFetch(true)
+					}
+					updateHeader()
+					updateStale()
+				})
+			}
+		}
+	}()
+
+	// banner only takes a row while the prices are stale
+	updateStale = func() {
+		age, fresh := stateAge(state)
+		switch {
+		case fresh:
+			root.ResizeItem(staleBanner, 0, 0)
+			return
+		case state.FetchedAt.IsZero():
+			staleBanner.SetText("[::b]No fetched prices yet[::-] — press " + km.Hint("fetch") + " to fetch")
+		default:
+			staleBanner.SetText(fmt.Sprintf("[::b]Prices are stale[::-] — fetched %s ago (TTL %s), press %s to refresh",
+				roundDuration(age), roundDuration(cacheTTL), km.Hint("fetch")))
+		}
+		root.ResizeItem(staleBanner, 1, 0)
+	}
+
+	applyTheme()
+	if opts.Notice != "" {
+		setStatus(opts.Notice)
+	}
+	if kmErr != nil {
+		setStatus(fmt.Sprintf("[red]Keymap ignored[-]: %v", kmErr))
+	}
+	if themeErr != nil {
+		setStatus(fmt.Sprintf("[red]Theme ignored[-]: %v", themeErr))
+	}
+	if _, fresh := stateAge(state); opts.FetchIfStale && !fresh {
+		doFetch(false)
+	}
+	app.SetBeforeDrawFunc(func(s tcell.Screen) bool {
+		screen = s
+		w, _ := s.Size()
+		if m := layoutFor(w); m != layoutMode {
+			layoutMode = m
+			applyLayout()
+		}
+		return false
+	})
+	// lay out once up front so body has items when SetRoot hands out focus;
+	// the first draw corrects the mode for the real width
+	layoutMode = layoutWide
+	applyLayout()
+	app.SetRoot(pages, true).SetFocus(cells[0].in)
+	return app.EnableMouse(!opts.NoMouse).Run()
 }
 
 func formatGPShort(v int64) string {
@@ -315,3 +1442,36 @@ func formatGPShort(v int64) string {
 		return strconv.FormatInt(v, 10)
 	}
 }
+
+// maxFetchLog is how many failed fetches the error log keeps.
+const maxFetchLog = 100
+
+type fetchLogEntry struct {
+	At  time.Time
+	Err error
+}
+
+// renderFetchLog lists failed fetches, oldest first, with the failing item,
+// HTTP status, attempts and how long they took when known.
+func renderFetchLog(log []fetchLogEntry) string {
+	if len(log) == 0 {
+		return "No fetch errors this session.\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[gray]%-9s %-16s %-9s %5s %8s  %s[-]\n", "time", "item", "status", "tries", "took", "error")
+	for _, e := range log {
+		item, status, tries, took, msg := "-", "-", "-", "-", e.Err
+		var fe *FetchError
+		if errors.As(e.Err, &fe) {
+			item, msg = fmt.Sprintf("%s (%d)", fe.Item, fe.ItemID), fe.Err
+			status = "no resp"
+			if fe.Status != 0 {
+				status = fmt.Sprintf("HTTP %d", fe.Status)
+			}
+			tries = strconv.Itoa(fe.Attempts)
+			took = fe.Took.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(&b, "%-9s %-16s %-9s %5s %8s  [red]%v[-]\n", e.At.Local().Format("15:04:05"), item, status, tries, took, msg)
+	}
+	return b.String()
+}
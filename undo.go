@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+/*
+   EDIT HISTORY (undo/redo of manual overrides)
+*/
+
+// Edit is one manual change in the TUI. Only the override layer is
+// snapshotted, so undo never rolls back fetched prices.
+type Edit struct {
+	Field     string
+	Old, New  string
+	At        time.Time
+	Overrides []PriceOverride // override layer after the edit
+}
+
+func (e Edit) String() string {
+	return fmt.Sprintf("%s %s → %s", e.Field, e.Old, e.New)
+}
+
+// EditHistory is a linear undo stack. Entry 0 is the starting point; pos is
+// the entry the state currently matches. Recording after an undo drops the
+// entries that could have been redone.
+type EditHistory struct {
+	entries []Edit
+	pos     int
+}
+
+func newEditHistory(overrides []PriceOverride) *EditHistory {
+	h := &EditHistory{}
+	h.Reset(overrides)
+	return h
+}
+
+// Reset starts over from overrides, e.g. after loading another cache.
+func (h *EditHistory) Reset(overrides []PriceOverride) {
+	h.entries = []Edit{{Field: "start", At: time.Now(), Overrides: slices.Clone(overrides)}}
+	h.pos = 0
+}
+
+func (h *EditHistory) Record(field, before, after string, overrides []PriceOverride) {
+	h.entries = append(h.entries[:h.pos+1], Edit{
+		Field:     field,
+		Old:       before,
+		New:       after,
+		At:        time.Now(),
+		Overrides: slices.Clone(overrides),
+	})
+	h.pos = len(h.entries) - 1
+}
+
+// Undo steps back one edit and returns the edit undone and the override
+// layer to restore.
+func (h *EditHistory) Undo() (Edit, []PriceOverride, bool) {
+	if h.pos == 0 {
+		return Edit{}, nil, false
+	}
+	undone := h.entries[h.pos]
+	h.pos--
+	return undone, slices.Clone(h.entries[h.pos].Overrides), true
+}
+
+func (h *EditHistory) Redo() (Edit, []PriceOverride, bool) {
+	if h.pos == len(h.entries)-1 {
+		return Edit{}, nil, false
+	}
+	h.pos++
+	e := h.entries[h.pos]
+	return e, slices.Clone(e.Overrides), true
+}
+
+// Goto moves to entry i (0 = start) and returns its override layer. Later
+// entries stay available for redo.
+func (h *EditHistory) Goto(i int) ([]PriceOverride, bool) {
+	if i < 0 || i >= len(h.entries) {
+		return nil, false
+	}
+	h.pos = i
+	return slices.Clone(h.entries[i].Overrides), true
+}
+
+func (h *EditHistory) Entries() []Edit { return h.entries }
+func (h *EditHistory) Pos() int        { return h.pos }
+
+// fieldValue describes field's current effective value for the history
+// list: "29.00k" for one component, "high/low/avg" for a bare target, "—"
+// when the field is unknown.
+func fieldValue(state AppState, field string) string {
+	eff := effectiveState(state, time.Now())
+	target, components, err := parseField(&eff, field)
+	if err != nil {
+		return "—"
+	}
+	t, _ := fieldTriple(&eff, target)
+	v := map[string]int64{"high": t.High, "low": t.Low, "avg": t.Avg}
+	if len(components) == 1 {
+		return formatGPShort(v[components[0]])
+	}
+	return fmt.Sprintf("%s/%s/%s", formatGPShort(t.High), formatGPShort(t.Low), formatGPShort(t.Avg))
+}
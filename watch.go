@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// The wiki asks consumers not to hammer the API; "latest" only moves
+	// about once a minute anyway, so never poll faster than that.
+	minPollInterval     = time.Minute
+	defaultPollInterval = 5 * time.Minute
+	maxPollBackoff      = 30 * time.Minute
+)
+
+/*
+   WATCH (background polling)
+*/
+
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	interval := fs.Duration("interval", defaultPollInterval, "time between fetches (minimum 1m)")
+	logPath := fs.String("log", "", "append output to this file instead of stdout")
+	metricsAddr := fs.String("metrics-addr", "", "serve Prometheus metrics on this address (e.g. 127.0.0.1:9108)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *logPath != "" {
+		f, err := os.OpenFile(*logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	logger := log.New(out, "", log.LstdFlags)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /metrics", handleMetrics)
+		srv := &http.Server{
+			Addr:              *metricsAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+		}
+		go func() {
+			logger.Printf("watch: metrics on http://%s/metrics", *metricsAddr)
+			if err := srv.ListenAndServe(); err != nil {
+				logger.Printf("watch: metrics server: %v", err)
+			}
+		}()
+	}
+
+	return watchLoop(ctx, instrumentedSource{wikiSource{}}, clampInterval(*interval), logger)
+}
+
+func watchLoop(ctx context.Context, src PriceSource, interval time.Duration, logger *log.Logger) error {
+	var prev *Report
+	if c, err := loadCache(); err == nil {
+		r := ComputeReport(c.State)
+		prev = &r
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		logger.Printf("watch: %v", err)
+	}
+
+	logger.Printf("watch: polling every %s", interval)
+	failures := 0
+	for {
+		last, _ := loadCachedState()
+		s, err := src.Fetch(last)
+		if err != nil {
+			failures++
+			logger.Printf("watch: fetch failed (%d in a row): %v", failures, err)
+		} else {
+			failures = 0
+			if len(s.Stale) > 0 {
+				logger.Printf("watch: partial fetch, kept earlier prices for %s", strings.Join(s.Stale, ", "))
+			}
+			if err := appendHistory(s); err != nil {
+				logger.Printf("watch: append history: %v", err)
+			}
+			if s, err = mergeIntoCache(s); err != nil {
+				logger.Printf("watch: save cache: %v", err)
+			}
+
+			cur := ComputeReport(s)
+			if prev == nil && len(cur.Armors) == 0 {
+				logger.Printf("watch: first fetch, no armors to compare")
+			} else if prev == nil {
+				logger.Printf("watch: first fetch, best by avg profit: %s (%s gp)",
+					cur.BestByAvgProfit.Name, comma(profitForLabel(cur.BestByAvgProfit, "avg")))
+			} else if lines := diffReports(*prev, cur); len(lines) == 0 {
+				logger.Printf("watch: no profit changes")
+			} else {
+				for _, l := range lines {
+					logger.Printf("watch: %s", l)
+				}
+			}
+			prev = &cur
+		}
+
+		wait := backoffDelay(interval, failures)
+		select {
+		case <-ctx.Done():
+			logger.Printf("watch: stopped")
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+func clampInterval(d time.Duration) time.Duration {
+	if d < minPollInterval {
+		return minPollInterval
+	}
+	return d
+}
+
+// backoffDelay doubles the interval for each consecutive failure, capped at
+// maxPollBackoff. Zero failures means the plain interval.
+func backoffDelay(interval time.Duration, failures int) time.Duration {
+	d := interval
+	for i := 0; i < failures && d < maxPollBackoff; i++ {
+		d *= 2
+	}
+	if d > maxPollBackoff {
+		d = max(maxPollBackoff, interval)
+	}
+	return d
+}
+
+// diffReports lists per-armor avg profit changes and ingredient cost moves
+// between two reports. Unchanged values are omitted.
+func diffReports(prev, cur Report) []string {
+	var lines []string
+	if d := cur.IngredientCost.Avg - prev.IngredientCost.Avg; d != 0 {
+		lines = append(lines, fmt.Sprintf("Ingredient cost avg: %s -> %s gp (%s)",
+			comma(prev.IngredientCost.Avg), comma(cur.IngredientCost.Avg), signedComma(d)))
+	}
+
+	before := make(map[int]ArmorReport, len(prev.Armors))
+	for _, a := range prev.Armors {
+		before[a.ItemID] = a
+	}
+	for _, a := range cur.Armors {
+		p := profitForLabel(a, "avg")
+		old, ok := before[a.ItemID]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("%s: avg profit %s gp (new)", a.Name, comma(p)))
+			continue
+		}
+		if d := p - profitForLabel(old, "avg"); d != 0 {
+			lines = append(lines, fmt.Sprintf("%s: avg profit %s -> %s gp (%s)",
+				a.Name, comma(profitForLabel(old, "avg")), comma(p), signedComma(d)))
+		}
+	}
+
+	if prev.BestByAvgProfit.ItemID != cur.BestByAvgProfit.ItemID {
+		lines = append(lines, fmt.Sprintf("Best by avg profit changed: %s -> %s",
+			prev.BestByAvgProfit.Name, cur.BestByAvgProfit.Name))
+	}
+	return lines
+}
+
+func signedComma(n int64) string {
+	if n > 0 {
+		return "+" + comma(n)
+	}
+	return comma(n)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// wikiUserAgent identifies the calculator to the wiki, with a contact URL as
+// its API guidelines ask. TUI, watch and serve all send it.
+const wikiUserAgent = "oathplate-calculator/" + version + " (+https://github.com/KRamPro/oathplateCalculator)"
+
+// maxRetryWait caps one backoff wait. A Retry-After longer than this is
+// not waited out; the request fails instead.
+const maxRetryWait = 30 * time.Second
+
+var (
+	// fetchRetries is how many times a transient failure (timeout, 429,
+	// 5xx) is retried (-retries).
+	fetchRetries = 2
+	// fetchRetryWait is the first backoff; it doubles per retry, with
+	// jitter (-retry-wait).
+	fetchRetryWait = 500 * time.Millisecond
+)
+
+// wikiClient is shared so connections to the wiki are kept alive between
+// requests. Timeouts are per attempt, see wikiGet.
+var wikiClient = &http.Client{}
+
+/*
+   WIKI HTTP (retries with backoff, response cache)
+*/
+
+// wikiResponse is what a wiki GET ended with. Status is 0 when no response
+// arrived; Attempts counts the first try too.
+type wikiResponse struct {
+	Body     []byte
+	Status   int
+	Attempts int
+}
+
+// wikiGet fetches url, retrying transient failures with exponential backoff
+// and jitter and honouring Retry-After. timeout applies to each attempt.
+// Anything but 200 OK is an error. With -offline the response comes from
+// the fixtures instead; with -record it is saved there.
+func wikiGet(ctx context.Context, url string, timeout time.Duration) (wikiResponse, error) {
+	if fixtures == fixturesReplay {
+		b, err := readFixture(url)
+		if err != nil {
+			return wikiResponse{Attempts: 1}, err
+		}
+		return wikiResponse{Body: b, Status: http.StatusOK, Attempts: 1}, nil
+	}
+
+	var res wikiResponse
+	for {
+		res.Attempts++
+		var retryAfter time.Duration
+		var err error
+		res.Body, res.Status, retryAfter, err = wikiGetOnce(ctx, url, timeout)
+		if err == nil {
+			if fixtures == fixturesRecord {
+				if err := writeFixture(url, res.Body); err != nil {
+					return res, fmt.Errorf("record fixture: %w", err)
+				}
+			}
+			return res, nil
+		}
+		if ctx.Err() != nil || res.Attempts > fetchRetries || !transient(res.Status, err) {
+			return res, attemptsErr(err, res.Attempts)
+		}
+
+		wait := backoff(res.Attempts)
+		if retryAfter > 0 {
+			if retryAfter > maxRetryWait {
+				return res, attemptsErr(fmt.Errorf("%w (Retry-After %s)", err, retryAfter), res.Attempts)
+			}
+			wait = retryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return res, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+type revalidateKey struct{}
+
+// withRevalidate marks ctx so wiki GETs made with it ask the wiki even when
+// the cached response is still fresh; a forced fetch wants current prices.
+func withRevalidate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, revalidateKey{}, true)
+}
+
+func revalidating(ctx context.Context) bool {
+	on, _ := ctx.Value(revalidateKey{}).(bool)
+	return on
+}
+
+// wikiGetOnce makes one attempt, going through the HTTP cache: a response
+// still fresh per Cache-Control is reused without asking (unless ctx is
+// revalidating), an older one is revalidated with If-None-Match /
+// If-Modified-Since.
+func wikiGetOnce(ctx context.Context, url string, timeout time.Duration) ([]byte, int, time.Duration, error) {
+	now := time.Now()
+	cached, haveCached := loadHTTPCache(url)
+	if haveCached && cached.fresh(now) && !revalidating(ctx) {
+		return cached.Body, http.StatusOK, 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	req.Header.Set("User-Agent", wikiUserAgent)
+	if haveCached {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := wikiClient.Do(req)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	// the cache is best effort: failing to write it never fails a fetch
+	store, freshUntil := cacheControl(resp.Header, now)
+	switch {
+	case resp.StatusCode == http.StatusNotModified && haveCached:
+		// a 304 may carry updated validators
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			cached.ETag = etag
+		}
+		if lm := resp.Header.Get("Last-Modified"); lm != "" {
+			cached.LastModified = lm
+		}
+		if store {
+			cached.FreshUntil = freshUntil
+			_ = saveHTTPCache(cached)
+		} else {
+			dropHTTPCache(url)
+		}
+		return cached.Body, resp.StatusCode, 0, nil
+	case resp.StatusCode != http.StatusOK:
+		wait, _ := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+		return nil, resp.StatusCode, wait, fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, 0, err
+	}
+	e := httpCacheEntry{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FreshUntil:   freshUntil,
+		StoredAt:     now,
+		Body:         b,
+	}
+	switch {
+	case !store:
+		dropHTTPCache(url)
+	case e.ETag != "" || e.LastModified != "" || !e.FreshUntil.IsZero():
+		_ = saveHTTPCache(e)
+	}
+	return b, resp.StatusCode, 0, nil
+}
+
+// transient reports whether a failure is worth retrying: timeouts, 429 and
+// 5xx. Anything else (404, DNS errors, bad JSON) would fail again.
+func transient(status int, err error) bool {
+	if status == http.StatusTooManyRequests || status >= 500 {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout() || errors.Is(err, context.DeadlineExceeded)
+}
+
+// backoff is the wait before retry n (1-based): fetchRetryWait doubled per
+// retry, capped at maxRetryWait, then drawn from its upper half.
+func backoff(n int) time.Duration {
+	if fetchRetryWait <= 0 {
+		return 0
+	}
+	d := fetchRetryWait << (n - 1)
+	if d <= 0 || d > maxRetryWait {
+		d = maxRetryWait
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// parseRetryAfter reads a Retry-After header: delay seconds or an HTTP date.
+func parseRetryAfter(h string, now time.Time) (time.Duration, bool) {
+	if h == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+func attemptsErr(err error, attempts int) error {
+	if attempts > 1 {
+		return fmt.Errorf("%w (after %d attempts)", err, attempts)
+	}
+	return err
+}